@@ -0,0 +1,272 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// adaptiveSettings controls how the circadian curve maps the sun to light
+type adaptiveSettings struct {
+	MinBrightness int // percent, used at night
+	MaxBrightness int // percent, used with the sun high
+	Warmest       int // Kelvin, used at night
+	Coolest       int // Kelvin, used with the sun high
+}
+
+// adaptiveTarget is the state the daemon last applied to a light
+type adaptiveTarget struct {
+	Bri int
+	CT  int // 0 for lights without color temperature
+}
+
+// Sun elevations (degrees) between which the curve moves from night to day:
+// civil twilight at the low end, mid-morning sun at the high end
+const (
+	adaptiveNightElevation = -6.0
+	adaptiveDayElevation   = 30.0
+)
+
+// circadianTarget returns the color temperature (mireds) and brightness
+// (percent) for a given sun elevation: warm and dim after dusk, cool and
+// bright with the sun high, easing smoothly in between
+func circadianTarget(elevation float64, s adaptiveSettings) (int, int) {
+	f := (elevation - adaptiveNightElevation) / (adaptiveDayElevation - adaptiveNightElevation)
+	f = math.Max(0, math.Min(1, f))
+	f = f * f * (3 - 2*f)
+
+	kelvin := float64(s.Warmest) + float64(s.Coolest-s.Warmest)*f
+	percent := float64(s.MinBrightness) + float64(s.MaxBrightness-s.MinBrightness)*f
+	return int(math.Round(1e6 / kelvin)), int(math.Round(percent))
+}
+
+func runAdaptive() {
+	adaptiveCmd := flag.NewFlagSet("adaptive", flag.ExitOnError)
+	roomList := adaptiveCmd.String("rooms", "", "Comma-separated rooms to adjust")
+	latFlag := adaptiveCmd.String("lat", "", "Latitude in degrees (default: HUE_LATITUDE)")
+	lonFlag := adaptiveCmd.String("lon", "", "Longitude in degrees (default: HUE_LONGITUDE)")
+	interval := adaptiveCmd.Duration("interval", time.Minute, "How often to adjust the lights")
+	transition := adaptiveCmd.Duration("transition", 10*time.Second, "Fade time for each adjustment")
+	resumeAfter := adaptiveCmd.Duration("resume-after", 2*time.Hour, "Resume a manually changed room after this long (it also resumes once switched off)")
+	minBrightness := adaptiveCmd.Int("min-brightness", 35, "Brightness percentage at night")
+	maxBrightness := adaptiveCmd.Int("max-brightness", 100, "Brightness percentage at midday")
+	warmest := adaptiveCmd.Int("warmest", 2200, "Color temperature at night, in Kelvin")
+	coolest := adaptiveCmd.Int("coolest", 5000, "Color temperature at midday, in Kelvin")
+	once := adaptiveCmd.Bool("once", false, "Adjust once and exit (e.g. from cron)")
+	adaptiveCmd.Parse(os.Args[2:])
+
+	settings := adaptiveSettings{
+		MinBrightness: *minBrightness,
+		MaxBrightness: *maxBrightness,
+		Warmest:       *warmest,
+		Coolest:       *coolest,
+	}
+	if settings.MinBrightness < 1 || settings.MaxBrightness > 100 || settings.MinBrightness > settings.MaxBrightness {
+		fmt.Println("Error: Brightness must satisfy 1 <= min-brightness <= max-brightness <= 100")
+		os.Exit(1)
+	}
+	if settings.Warmest < 2000 || settings.Coolest > 6500 || settings.Warmest > settings.Coolest {
+		fmt.Println("Error: Color temperature must satisfy 2000 <= warmest <= coolest <= 6500")
+		os.Exit(1)
+	}
+	if *transition >= *interval {
+		fmt.Println("Error: Transition must be shorter than the interval")
+		os.Exit(1)
+	}
+
+	var rooms []string
+	for _, r := range strings.Split(*roomList, ",") {
+		if r = strings.TrimSpace(r); r != "" {
+			rooms = append(rooms, r)
+		}
+	}
+	if len(rooms) == 0 {
+		fmt.Println("Error: --rooms is required, e.g. --rooms \"Living Room,Office\"")
+		os.Exit(1)
+	}
+
+	lat, lon, err := configLocation(*latFlag, *lonFlag)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	config, err := loadConfig()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	logf := func(format string, args ...interface{}) {
+		fmt.Printf("%s  %s\n", time.Now().Format("2006-01-02 15:04:05"), fmt.Sprintf(format, args...))
+	}
+
+	d := &adaptiveDaemon{
+		config:      config,
+		rooms:       rooms,
+		settings:    settings,
+		transition:  *transition,
+		resumeAfter: *resumeAfter,
+		lastSet:     map[string]adaptiveTarget{},
+		paused:      map[string]time.Time{},
+		logf:        logf,
+	}
+
+	if err := d.adjust(lat, lon); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	if *once {
+		return
+	}
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	ticker := time.NewTicker(*interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			d.config = beginPolicyCommand(config)
+			if err := d.adjust(lat, lon); err != nil {
+				logf("Error: %v", err)
+			}
+		case <-stop:
+			logf("Adaptive lighting stopped")
+			return
+		}
+	}
+}
+
+// adaptiveDaemon keeps track of what it set on each light so it can tell
+// its own changes apart from manual ones
+type adaptiveDaemon struct {
+	config      *Config
+	rooms       []string
+	settings    adaptiveSettings
+	transition  time.Duration
+	resumeAfter time.Duration
+	lastSet     map[string]adaptiveTarget // light ID -> state we applied
+	paused      map[string]time.Time      // room name -> when it was paused
+	logf        func(format string, args ...interface{})
+}
+
+// adjust moves every light that is on in the selected rooms towards the
+// current circadian target, skipping rooms that were changed by hand
+func (d *adaptiveDaemon) adjust(lat, lon float64) error {
+	groups, err := getGroups(d.config)
+	if err != nil {
+		return err
+	}
+	lights, err := getLights(d.config)
+	if err != nil {
+		return err
+	}
+
+	elevation := solarElevation(time.Now(), lat, lon)
+	ct, percent := circadianTarget(elevation, d.settings)
+
+	for _, room := range d.rooms {
+		groupID, ok := findGroup(groups, room)
+		if !ok {
+			d.logf("Room '%s' not found, skipping", room)
+			continue
+		}
+		group := groups[groupID]
+
+		anyOn := false
+		for _, id := range group.Lights {
+			if lights[id].State.On {
+				anyOn = true
+			}
+		}
+
+		if since, paused := d.paused[group.Name]; paused {
+			if anyOn && time.Since(since) < d.resumeAfter {
+				continue
+			}
+			delete(d.paused, group.Name)
+			for _, id := range group.Lights {
+				delete(d.lastSet, id)
+			}
+			d.logf("Resuming %s", group.Name)
+		}
+
+		if changed := d.manuallyChanged(group, lights); changed != "" {
+			d.paused[group.Name] = time.Now()
+			d.logf("Pausing %s: '%s' was changed manually", group.Name, changed)
+			continue
+		}
+
+		for _, id := range group.Lights {
+			light := lights[id]
+			if !light.State.On || !light.State.Reachable {
+				delete(d.lastSet, id)
+				continue
+			}
+
+			target := adaptiveTarget{Bri: percentToBri(percent)}
+			state := map[string]interface{}{
+				"bri":            target.Bri,
+				"transitiontime": int(d.transition / (100 * time.Millisecond)),
+			}
+			if light.supportsCT() {
+				target.CT = light.clampCT(ct)
+				state["ct"] = target.CT
+			}
+
+			if matchesAdaptiveTarget(light.State, target) {
+				d.lastSet[id] = target
+				continue
+			}
+			if err := setLightState(d.config, id, state); err != nil {
+				d.logf("Error adjusting '%s': %v", light.Name, err)
+				continue
+			}
+			d.lastSet[id] = target
+			d.logf("%s: '%s' -> %d%% at %dK (sun %.1f°)", group.Name, light.Name, percent, 1000000/ct, elevation)
+		}
+	}
+	return nil
+}
+
+// manuallyChanged returns the name of a light in the group whose state no
+// longer matches what the daemon last applied, or "" if none
+func (d *adaptiveDaemon) manuallyChanged(group Group, lights map[string]Light) string {
+	for _, id := range group.Lights {
+		last, ok := d.lastSet[id]
+		light := lights[id]
+		if !ok || !light.State.On || !light.State.Reachable {
+			continue
+		}
+		if !matchesAdaptiveTarget(light.State, last) {
+			return light.Name
+		}
+	}
+	return ""
+}
+
+// matchesAdaptiveTarget compares a light's state with a target, allowing
+// for the rounding the bridge applies
+func matchesAdaptiveTarget(state LightState, target adaptiveTarget) bool {
+	if abs(state.Bri-target.Bri) > 3 {
+		return false
+	}
+	if target.CT > 0 && (state.ColorMode != "ct" || abs(state.CT-target.CT) > 5) {
+		return false
+	}
+	return true
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
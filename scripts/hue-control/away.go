@@ -0,0 +1,543 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// awayTemplate lists the windows during which each room should be lit.
+// The room name "*" applies to rooms without their own entry.
+type awayTemplate struct {
+	Rooms map[string][]awayWindow `json:"rooms"`
+}
+
+// awayWindow is a period a room is lit, as "HH:MM" clock times or sun times
+// such as "sunset-30m". An off time earlier than the on time means the
+// window runs past midnight.
+type awayWindow struct {
+	On  string `json:"on"`
+	Off string `json:"off"`
+}
+
+// defaultAwayTemplate is used when there is neither a template nor enough history
+var defaultAwayTemplate = awayTemplate{
+	Rooms: map[string][]awayWindow{
+		"*": {{On: "18:30", Off: "22:45"}},
+	},
+}
+
+// historySample is one line of the room history recorded by 'away record'
+type historySample struct {
+	Time  time.Time       `json:"time"`
+	Rooms map[string]bool `json:"rooms"`
+}
+
+// awayEvent is a planned room on/off switch
+type awayEvent struct {
+	At   time.Time
+	Room string
+	On   bool
+}
+
+// The evening used for learning runs from this hour until the same hour
+// minus 11h the next morning, so late nights count towards the evening before
+const eveningStartHour = 15
+
+func awayHistoryPath() (string, error) {
+	return userFilePath("history.jsonl")
+}
+
+func runAway() {
+	if len(os.Args) >= 3 && os.Args[2] == "record" {
+		runAwayRecord()
+		return
+	}
+
+	awayCmd := flag.NewFlagSet("away", flag.ExitOnError)
+	roomList := awayCmd.String("rooms", "", "Comma-separated rooms to simulate presence in")
+	templatePath := awayCmd.String("template", "", "JSON template with on/off windows per room")
+	historyPath := awayCmd.String("history", "", "History file to learn typical on/off times from (default: recorded history)")
+	jitter := awayCmd.Duration("jitter", 20*time.Minute, "Maximum random shift applied to every on/off time")
+	logPath := awayCmd.String("log", "", "Also append every action to this file")
+	dryRun := awayCmd.Bool("dry-run", false, "Print today's plan without switching lights")
+	awayCmd.Parse(os.Args[2:])
+
+	var rooms []string
+	for _, r := range strings.Split(*roomList, ",") {
+		if r = strings.TrimSpace(r); r != "" {
+			rooms = append(rooms, r)
+		}
+	}
+	if len(rooms) == 0 {
+		fmt.Println("Error: --rooms is required, e.g. --rooms \"Living Room,Kitchen\"")
+		os.Exit(1)
+	}
+
+	config, err := loadConfig()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	groups, err := getGroups(config)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	for i, r := range rooms {
+		id, ok := findGroup(groups, r)
+		if !ok {
+			fmt.Printf("Error: room '%s' not found. Use 'hue-control list' to see available rooms\n", r)
+			os.Exit(1)
+		}
+		rooms[i] = groups[id].Name
+	}
+
+	template, source, err := loadAwayTemplate(*templatePath, *historyPath, rooms)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	out := io.Writer(os.Stdout)
+	if *logPath != "" {
+		f, err := os.OpenFile(*logPath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		defer f.Close()
+		out = io.MultiWriter(os.Stdout, f)
+	}
+	logf := func(format string, args ...interface{}) {
+		fmt.Fprintf(out, "%s  %s\n", time.Now().Format("2006-01-02 15:04:05"), fmt.Sprintf(format, args...))
+	}
+
+	loc, err := awayLocation(template)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	logf("Away mode using %s for %s (jitter ±%s)", source, strings.Join(rooms, ", "), *jitter)
+
+	lit := map[string]bool{} // rooms this simulation switched on
+	apply := func(room string, on bool, reason string) {
+		if *dryRun {
+			return
+		}
+		if err := setRoomPower(beginPolicyCommand(config), room, on); err != nil {
+			logf("Error turning %s %s: %v", room, onOff(on), err)
+			return
+		}
+		lit[room] = on
+		logf("Turned %s %s (%s)", room, onOff(on), reason)
+	}
+
+	var plan []awayEvent
+	plannedDay := ""
+	makePlan := func(now time.Time) {
+		p, skipped, err := planAwayDay(template, rooms, now, *jitter, loc)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		plan, plannedDay = p, now.Format("2006-01-02")
+		for _, note := range skipped {
+			logf("Skipped: %s", note)
+		}
+		for _, e := range plan {
+			logf("Planned: %s %s at %s", e.Room, onOff(e.On), e.At.Format("15:04"))
+		}
+		// Rooms whose window is already open are switched on right away
+		for _, room := range rooms {
+			if roomLitAt(plan, room, now) && !lit[room] {
+				apply(room, true, "window already open")
+			}
+		}
+	}
+
+	makePlan(time.Now())
+	if *dryRun {
+		return
+	}
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+
+	for {
+		now := time.Now()
+		var next *awayEvent
+		for i := range plan {
+			if plan[i].At.After(now) {
+				next = &plan[i]
+				break
+			}
+		}
+
+		var wait time.Duration
+		if next != nil {
+			wait = next.At.Sub(now)
+		} else if plannedDay != now.Format("2006-01-02") {
+			makePlan(now)
+			continue
+		} else {
+			// Nothing left today: plan the next day shortly after midnight
+			tomorrow := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 1, 0, 0, now.Location())
+			wait = tomorrow.Sub(now)
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-stop:
+			timer.Stop()
+			for _, room := range rooms {
+				if lit[room] {
+					apply(room, false, "away mode stopped")
+				}
+			}
+			logf("Away mode stopped")
+			return
+		case <-timer.C:
+		}
+
+		if next != nil {
+			apply(next.Room, next.On, "planned for "+next.At.Format("15:04"))
+		}
+	}
+}
+
+func onOff(on bool) string {
+	if on {
+		return "on"
+	}
+	return "off"
+}
+
+// loadAwayTemplate returns the template to simulate and a description of
+// where it came from: an explicit template, learned history, or the default
+func loadAwayTemplate(templatePath, historyPath string, rooms []string) (awayTemplate, string, error) {
+	if templatePath != "" {
+		data, err := os.ReadFile(templatePath)
+		if err != nil {
+			return awayTemplate{}, "", err
+		}
+		var t awayTemplate
+		if err := json.Unmarshal(data, &t); err != nil {
+			return awayTemplate{}, "", fmt.Errorf("invalid template %s: %v", templatePath, err)
+		}
+		return t, "template " + templatePath, nil
+	}
+
+	explicit := historyPath != ""
+	if !explicit {
+		var err error
+		if historyPath, err = awayHistoryPath(); err != nil {
+			return awayTemplate{}, "", err
+		}
+	}
+
+	t, days, err := learnAwayTemplate(historyPath, rooms)
+	if err == nil {
+		return t, fmt.Sprintf("%d days of history from %s", days, historyPath), nil
+	}
+	if explicit {
+		return awayTemplate{}, "", err
+	}
+	return defaultAwayTemplate, fmt.Sprintf("the default evening template (%v)", err), nil
+}
+
+// awayLocation checks every time in the template and returns the configured
+// location if any of them are sun times, or nil if none are
+func awayLocation(t awayTemplate) (*geoLocation, error) {
+	sunRelative := false
+	for room, windows := range t.Rooms {
+		for _, w := range windows {
+			for _, s := range []string{w.On, w.Off} {
+				spec, err := parseTimeSpec(s)
+				if err != nil {
+					return nil, fmt.Errorf("room '%s': %v", room, err)
+				}
+				sunRelative = sunRelative || spec.sunRelative()
+			}
+		}
+	}
+	if !sunRelative {
+		return nil, nil
+	}
+
+	lat, lon, err := configLocation("", "")
+	if err != nil {
+		return nil, fmt.Errorf("template uses sun times: %v", err)
+	}
+	return &geoLocation{Lat: lat, Lon: lon}, nil
+}
+
+// planAwayDay builds the sorted on/off events for the day containing now,
+// shifting every time by up to ±jitter. Windows using a sun time that does
+// not happen that day (polar day or night) are skipped and described in
+// the returned notes.
+func planAwayDay(t awayTemplate, rooms []string, now time.Time, jitter time.Duration, loc *geoLocation) ([]awayEvent, []string, error) {
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	shift := func() time.Duration {
+		if jitter <= 0 {
+			return 0
+		}
+		return time.Duration(rand.Int63n(int64(2*jitter))) - jitter
+	}
+
+	var events []awayEvent
+	var skipped []string
+	for _, room := range rooms {
+		windows, ok := t.Rooms[room]
+		if !ok {
+			for name, w := range t.Rooms {
+				if strings.EqualFold(name, room) {
+					windows, ok = w, true
+				}
+			}
+		}
+		if !ok {
+			windows, ok = t.Rooms["*"]
+		}
+		if !ok {
+			return nil, nil, fmt.Errorf("template has no windows for room '%s'", room)
+		}
+
+		for _, w := range windows {
+			on, off, err := awayWindowTimes(day, w, loc)
+			var sunErr *sunEventError
+			if errors.As(err, &sunErr) {
+				skipped = append(skipped, fmt.Sprintf("%s %s to %s: %v", room, w.On, w.Off, err))
+				continue
+			}
+			if err != nil {
+				return nil, nil, fmt.Errorf("room '%s': %v", room, err)
+			}
+			on = on.Add(shift())
+			off = off.Add(shift())
+			if off.Sub(on) < 5*time.Minute {
+				off = on.Add(5 * time.Minute)
+			}
+			events = append(events, awayEvent{At: on, Room: room, On: true}, awayEvent{At: off, Room: room, On: false})
+		}
+	}
+
+	sort.SliceStable(events, func(i, j int) bool { return events[i].At.Before(events[j].At) })
+	return events, skipped, nil
+}
+
+// awayWindowTimes resolves a window's times on the given day. An off time
+// that is not after the on time is taken from the following day.
+func awayWindowTimes(day time.Time, w awayWindow, loc *geoLocation) (time.Time, time.Time, error) {
+	onSpec, err := parseTimeSpec(w.On)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	offSpec, err := parseTimeSpec(w.Off)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+
+	on, err := onSpec.on(day, loc)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	off, err := offSpec.on(day, loc)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	if !off.After(on) {
+		if off, err = offSpec.on(day.AddDate(0, 0, 1), loc); err != nil {
+			return time.Time{}, time.Time{}, err
+		}
+	}
+	return on, off, nil
+}
+
+// roomLitAt reports whether the plan has the room on at time t
+func roomLitAt(plan []awayEvent, room string, t time.Time) bool {
+	lit := false
+	for _, e := range plan {
+		if e.Room == room && !e.At.After(t) {
+			lit = e.On
+		}
+	}
+	return lit
+}
+
+// learnAwayTemplate derives each room's typical evening window from the
+// recorded history: the median time it was first switched on after
+// eveningStartHour and the median time it was last switched off.
+func learnAwayTemplate(path string, rooms []string) (awayTemplate, int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return awayTemplate{}, 0, fmt.Errorf("no history recorded yet; run 'hue-control away record' while at home")
+		}
+		return awayTemplate{}, 0, err
+	}
+	defer f.Close()
+
+	var samples []historySample
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var s historySample
+		if json.Unmarshal(scanner.Bytes(), &s) == nil && !s.Time.IsZero() {
+			samples = append(samples, s)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return awayTemplate{}, 0, err
+	}
+	sort.Slice(samples, func(i, j int) bool { return samples[i].Time.Before(samples[j].Time) })
+
+	// Minutes since the start of the evening, per room and evening
+	type evening struct{ on, off int }
+	perRoom := map[string]map[string]*evening{}
+	allEvenings := map[string]bool{}
+
+	for i, s := range samples {
+		local := s.Time.Local()
+		start := time.Date(local.Year(), local.Month(), local.Day(), eveningStartHour, 0, 0, 0, local.Location())
+		if local.Before(start) {
+			start = start.AddDate(0, 0, -1)
+		}
+		key := start.Format("2006-01-02")
+		minute := int(local.Sub(start).Minutes())
+		allEvenings[key] = true
+
+		for _, room := range rooms {
+			on := s.Rooms[room]
+			prev := false
+			if i > 0 {
+				prev = samples[i-1].Rooms[room]
+			}
+			if perRoom[room] == nil {
+				perRoom[room] = map[string]*evening{}
+			}
+			e := perRoom[room][key]
+			if on && !prev && (e == nil || e.on < 0) && local.Hour() >= eveningStartHour {
+				if e == nil {
+					e = &evening{on: -1, off: -1}
+					perRoom[room][key] = e
+				}
+				e.on = minute
+			}
+			if !on && prev && e != nil && e.on >= 0 {
+				e.off = minute
+			}
+		}
+	}
+
+	if len(allEvenings) < 3 {
+		return awayTemplate{}, 0, fmt.Errorf("only %d days of history; at least 3 are needed", len(allEvenings))
+	}
+
+	t := awayTemplate{Rooms: map[string][]awayWindow{}}
+	for _, room := range rooms {
+		var ons, offs []int
+		for _, e := range perRoom[room] {
+			if e.on >= 0 && e.off > e.on {
+				ons = append(ons, e.on)
+				offs = append(offs, e.off)
+			}
+		}
+		if len(ons) == 0 {
+			// Never used in the evening: leave it dark
+			t.Rooms[room] = []awayWindow{}
+			continue
+		}
+		format := func(minutes int) string {
+			minutes = (eveningStartHour*60 + minutes) % (24 * 60)
+			return fmt.Sprintf("%02d:%02d", minutes/60, minutes%60)
+		}
+		t.Rooms[room] = []awayWindow{{On: format(median(ons)), Off: format(median(offs))}}
+	}
+	return t, len(allEvenings), nil
+}
+
+func median(values []int) int {
+	sorted := append([]int(nil), values...)
+	sort.Ints(sorted)
+	return sorted[len(sorted)/2]
+}
+
+// runAwayRecord samples which rooms are lit and appends them to the history
+// file, so 'away' can later reproduce the household's usual pattern
+func runAwayRecord() {
+	recordCmd := flag.NewFlagSet("away record", flag.ExitOnError)
+	interval := recordCmd.Duration("interval", 5*time.Minute, "How often to sample which rooms are on")
+	recordCmd.Parse(os.Args[3:])
+
+	if *interval < 10*time.Second {
+		fmt.Println("Error: Interval must be at least 10s")
+		os.Exit(1)
+	}
+
+	config, err := loadConfig()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	path, err := awayHistoryPath()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	defer f.Close()
+
+	fmt.Printf("Recording room history every %s to %s (Ctrl-C to stop)\n", *interval, path)
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	ticker := time.NewTicker(*interval)
+	defer ticker.Stop()
+
+	for {
+		groups, err := getGroups(config)
+		if err != nil {
+			fmt.Printf("Error reading bridge state: %v\n", err)
+		} else {
+			sample := historySample{Time: time.Now(), Rooms: map[string]bool{}}
+			for _, group := range groups {
+				if group.Type == "Room" || group.Type == "Zone" {
+					sample.Rooms[group.Name] = group.State.AnyOn
+				}
+			}
+			line, _ := json.Marshal(sample)
+			if _, err := f.Write(append(line, '\n')); err != nil {
+				fmt.Printf("Error writing history: %v\n", err)
+			}
+		}
+
+		select {
+		case <-ticker.C:
+		case <-stop:
+			fmt.Println("Stopped recording")
+			return
+		}
+	}
+}
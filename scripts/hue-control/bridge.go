@@ -0,0 +1,198 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// bridgeURL builds a v1 API URL for a resource path such as "/groups"
+func bridgeURL(config *Config, path string) string {
+	return fmt.Sprintf("https://%s/api/%s%s", config.BridgeIP, config.APIKey, path)
+}
+
+// bridgeGet fetches a v1 API resource and decodes the response into v
+func bridgeGet(config *Config, path string, v interface{}) error {
+	client := getHTTPClient()
+
+	resp, err := client.Get(bridgeURL(config, path))
+	if err != nil {
+		return fmt.Errorf("failed to connect to bridge: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %v", err)
+	}
+
+	// The bridge answers with an error list (e.g. unauthorized user)
+	// instead of the requested object when something is wrong
+	var errs []struct {
+		Error *struct {
+			Description string `json:"description"`
+		} `json:"error"`
+	}
+	if json.Unmarshal(body, &errs) == nil && len(errs) > 0 && errs[0].Error != nil {
+		return fmt.Errorf("bridge error: %s", errs[0].Error.Description)
+	}
+
+	if err := json.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("invalid response: %v", err)
+	}
+
+	return nil
+}
+
+// sortedIDs returns the keys of a bridge resource map in numeric order
+func sortedIDs[T any](resources map[string]T) []string {
+	ids := make([]string, 0, len(resources))
+	for id := range resources {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		a, errA := strconv.Atoi(ids[i])
+		b, errB := strconv.Atoi(ids[j])
+		if errA == nil && errB == nil {
+			return a < b
+		}
+		return ids[i] < ids[j]
+	})
+	return ids
+}
+
+// bridgeResult is a single entry of the list the bridge returns for write requests
+type bridgeResult struct {
+	Success map[string]interface{} `json:"success,omitempty"`
+	Error   *struct {
+		Type        int    `json:"type"`
+		Address     string `json:"address"`
+		Description string `json:"description"`
+	} `json:"error,omitempty"`
+}
+
+// bridgeWrite checks a POST, PUT or DELETE request to a v1 API resource
+// against the safety policy, sends it and returns the success entries. Any
+// error entries reported by the bridge are combined into the returned error.
+func bridgeWrite(config *Config, method, path string, body interface{}) ([]map[string]interface{}, error) {
+	if err := checkPolicy(config, method, path, body); err != nil {
+		return nil, err
+	}
+	client := getHTTPClient()
+
+	var reader io.Reader
+	if body != nil {
+		jsonBody, err := json.Marshal(body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode request: %v", err)
+		}
+		reader = bytes.NewBuffer(jsonBody)
+	}
+
+	req, err := http.NewRequest(method, bridgeURL(config, path), reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to bridge: %v", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %v", err)
+	}
+
+	var results []bridgeResult
+	if err := json.Unmarshal(respBody, &results); err != nil {
+		return nil, fmt.Errorf("invalid response: %v", err)
+	}
+
+	var successes []map[string]interface{}
+	var errs []string
+	for _, r := range results {
+		if r.Error != nil {
+			errs = append(errs, r.Error.Description)
+		} else if r.Success != nil {
+			successes = append(successes, r.Success)
+		}
+	}
+	if len(errs) > 0 {
+		return successes, fmt.Errorf("bridge error: %s", strings.Join(errs, "; "))
+	}
+
+	return successes, nil
+}
+
+// bridgeV2Request sends a request to a v2 (CLIP) API resource such as
+// "/resource/light" and decodes the response's data list into v, if given.
+// The v2 API is needed for features v1 does not expose, e.g. gradients.
+func bridgeV2Request(config *Config, method, path string, body, v interface{}) error {
+	if err := checkPolicyV2(config, method, path, body); err != nil {
+		return err
+	}
+	client := getHTTPClient()
+
+	var reader io.Reader
+	if body != nil {
+		jsonBody, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to encode request: %v", err)
+		}
+		reader = bytes.NewBuffer(jsonBody)
+	}
+
+	url := fmt.Sprintf("https://%s/clip/v2%s", config.BridgeIP, path)
+	req, err := http.NewRequest(method, url, reader)
+	if err != nil {
+		return fmt.Errorf("failed to build request: %v", err)
+	}
+	req.Header.Set("hue-application-key", config.APIKey)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to connect to bridge: %v", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %v", err)
+	}
+
+	var result struct {
+		Errors []struct {
+			Description string `json:"description"`
+		} `json:"errors"`
+		Data json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(respBody, &result); err != nil {
+		if resp.StatusCode == http.StatusNotFound {
+			return fmt.Errorf("bridge does not support the v2 API; update its firmware")
+		}
+		return fmt.Errorf("invalid response: %v", err)
+	}
+	if len(result.Errors) > 0 {
+		var errs []string
+		for _, e := range result.Errors {
+			errs = append(errs, e.Description)
+		}
+		return fmt.Errorf("bridge error: %s", strings.Join(errs, "; "))
+	}
+
+	if v != nil {
+		if err := json.Unmarshal(result.Data, v); err != nil {
+			return fmt.Errorf("invalid response: %v", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// brightnessCurve maps brightness percentages to the bridge's brightness
+// level and back. With "linear" 10% is 10% of the bridge range, which looks
+// much brighter than expected; "cie" and "gamma" treat the percentage as
+// perceived lightness, giving finer steps at the low end.
+type brightnessCurve struct {
+	Name  string  // linear, cie or gamma
+	Gamma float64 // exponent for gamma
+}
+
+// parseBrightnessCurve accepts "linear", "cie", "gamma" or "gamma:<exponent>"
+func parseBrightnessCurve(s string) (brightnessCurve, error) {
+	name, arg, hasArg := strings.Cut(strings.ToLower(strings.TrimSpace(s)), ":")
+	switch name {
+	case "", "linear":
+		if hasArg {
+			break
+		}
+		return brightnessCurve{Name: "linear"}, nil
+	case "cie":
+		if hasArg {
+			break
+		}
+		return brightnessCurve{Name: "cie"}, nil
+	case "gamma":
+		gamma := 2.2
+		if hasArg {
+			g, err := strconv.ParseFloat(arg, 64)
+			if err != nil || g <= 0 {
+				return brightnessCurve{}, fmt.Errorf("invalid gamma '%s', expected a positive number like 2.2", arg)
+			}
+			gamma = g
+		}
+		return brightnessCurve{Name: "gamma", Gamma: gamma}, nil
+	}
+	return brightnessCurve{}, fmt.Errorf("invalid brightness curve '%s', expected linear, cie or gamma[:<exponent>]", s)
+}
+
+// toLevel converts a fraction of perceived brightness (0-1) to a fraction
+// of the bridge range
+func (c brightnessCurve) toLevel(p float64) float64 {
+	switch c.Name {
+	case "cie":
+		// CIE 1976 lightness L* (0-100) to relative luminance
+		l := p * 100
+		if l <= 8 {
+			return l / 903.3
+		}
+		return math.Pow((l+16)/116, 3)
+	case "gamma":
+		return math.Pow(p, c.Gamma)
+	}
+	return p
+}
+
+// fromLevel is the inverse of toLevel
+func (c brightnessCurve) fromLevel(level float64) float64 {
+	switch c.Name {
+	case "cie":
+		if level <= 0.008856 {
+			return level * 903.3 / 100
+		}
+		return (116*math.Cbrt(level) - 16) / 100
+	case "gamma":
+		return math.Pow(level, 1/c.Gamma)
+	}
+	return level
+}
+
+// bri converts a brightness percentage to the bridge's 1-254 range; any
+// percentage above 0 gives at least 1
+func (c brightnessCurve) bri(percent int) int {
+	bri := int(math.Round(c.toLevel(float64(percent)/100) * 254))
+	if bri < 1 && percent > 0 {
+		bri = 1
+	}
+	return bri
+}
+
+// percent converts the bridge's 1-254 brightness to a percentage
+func (c brightnessCurve) percent(bri int) int {
+	return int(math.Round(c.fromLevel(float64(bri)/254) * 100))
+}
+
+// percentToDimming converts a brightness percentage to the v2 API's
+// dimming brightness, a percentage of the bridge range, so it follows the
+// configured curve and matches the level percentToBri gives the v1 API
+func percentToDimming(percent int) float64 {
+	return float64(percentToBri(percent)) / 254 * 100
+}
+
+// dimmingToBri converts the v2 API's dimming brightness to the bridge's
+// 1-254 brightness
+func dimmingToBri(brightness float64) int {
+	return int(math.Round(brightness / 100 * 254))
+}
+
+var (
+	curveOnce       sync.Once
+	configuredCurve brightnessCurve
+)
+
+// activeBrightnessCurve returns the curve set by HUE_BRIGHTNESS_CURVE. An
+// invalid setting is reported once and the linear curve is used.
+func activeBrightnessCurve() brightnessCurve {
+	curveOnce.Do(func() {
+		curve, err := parseBrightnessCurve(configValue("HUE_BRIGHTNESS_CURVE"))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: HUE_BRIGHTNESS_CURVE: %v; using linear\n", err)
+			curve = brightnessCurve{Name: "linear"}
+		}
+		configuredCurve = curve
+	})
+	return configuredCurve
+}
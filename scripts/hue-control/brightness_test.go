@@ -0,0 +1,83 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestBrightnessCurve(t *testing.T) {
+	linear := brightnessCurve{Name: "linear"}
+	cie := brightnessCurve{Name: "cie"}
+	gamma := brightnessCurve{Name: "gamma", Gamma: 2.2}
+
+	tests := []struct {
+		curve   brightnessCurve
+		percent int
+		bri     int
+	}{
+		{linear, 0, 0},
+		{linear, 1, 3},
+		{linear, 50, 127},
+		{linear, 100, 254},
+		{cie, 0, 0},
+		{cie, 1, 1}, // never rounded down to off
+		{cie, 50, 47},
+		{cie, 100, 254},
+		{gamma, 0, 0},
+		{gamma, 1, 1},
+		{gamma, 50, 55},
+		{gamma, 100, 254},
+	}
+	for _, tt := range tests {
+		if got := tt.curve.bri(tt.percent); got != tt.bri {
+			t.Errorf("%s: bri(%d) = %d, want %d", tt.curve.Name, tt.percent, got, tt.bri)
+		}
+	}
+
+	// From 10% up a percentage comes back from the bridge range within a
+	// point; below that the curves have fewer levels than percentages.
+	// Brighter percentages never give a lower level.
+	for _, c := range []brightnessCurve{linear, cie, gamma} {
+		prev := -1
+		for p := 0; p <= 100; p++ {
+			bri := c.bri(p)
+			if bri < prev {
+				t.Errorf("%s: bri(%d) = %d, below bri(%d) = %d", c.Name, p, bri, p-1, prev)
+			}
+			prev = bri
+			if got := c.percent(bri); p >= 10 && (got < p-1 || got > p+1) {
+				t.Errorf("%s: percent(bri(%d)) = %d", c.Name, p, got)
+			}
+		}
+		for _, level := range []float64{0, 0.005, 0.25, 0.5, 1} {
+			if got := c.toLevel(c.fromLevel(level)); math.Abs(got-level) > 1e-9 {
+				t.Errorf("%s: toLevel(fromLevel(%v)) = %v", c.Name, level, got)
+			}
+		}
+	}
+}
+
+func TestParseBrightnessCurve(t *testing.T) {
+	tests := []struct {
+		in   string
+		want brightnessCurve
+	}{
+		{"", brightnessCurve{Name: "linear"}},
+		{"linear", brightnessCurve{Name: "linear"}},
+		{" CIE ", brightnessCurve{Name: "cie"}},
+		{"gamma", brightnessCurve{Name: "gamma", Gamma: 2.2}},
+		{"gamma:1.8", brightnessCurve{Name: "gamma", Gamma: 1.8}},
+	}
+	for _, tt := range tests {
+		got, err := parseBrightnessCurve(tt.in)
+		if err != nil || got != tt.want {
+			t.Errorf("parseBrightnessCurve(%q) = %+v, %v, want %+v", tt.in, got, err, tt.want)
+		}
+	}
+
+	for _, in := range []string{"log", "linear:2", "cie:1", "gamma:0", "gamma:x"} {
+		if _, err := parseBrightnessCurve(in); err == nil {
+			t.Errorf("parseBrightnessCurve(%q): no error", in)
+		}
+	}
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// lightKind names what a light can do: "color", "white ambiance", "white"
+// (dimmable only) or "on/off"
+func lightKind(l Light) string {
+	switch {
+	case l.supportsColor():
+		return "color"
+	case l.supportsCT():
+		return "white ambiance"
+	case l.dimmable():
+		return "white"
+	default:
+		return "on/off"
+	}
+}
+
+// translateState adapts a requested state, which may hold bri and one of
+// xy, ct or hue/sat, to what the light supports: colors become the nearest
+// white on ambiance bulbs and are dropped on dimmable-only bulbs. It
+// returns the state to send and a description of the translation, or ""
+// if the state is sent unchanged.
+func translateState(light Light, state map[string]interface{}) (map[string]interface{}, string) {
+	out := map[string]interface{}{}
+	for key, value := range state {
+		out[key] = value
+	}
+	_, hasXY := out["xy"]
+	_, hasCT := out["ct"]
+	_, hasHue := out["hue"]
+	_, hasSat := out["sat"]
+	hasColor := hasXY || hasHue || hasSat
+
+	switch lightKind(light) {
+	case "color":
+		if hasCT && !light.supportsCT() {
+			ct := intValue(out["ct"])
+			x, y := ctToXY(ct)
+			delete(out, "ct")
+			out["xy"] = [2]float64{math.Round(x*10000) / 10000, math.Round(y*10000) / 10000}
+			return out, fmt.Sprintf("ct %d as xy", ct)
+		}
+		return out, ""
+
+	case "white ambiance":
+		if hasColor {
+			x, y := requestedXY(light, out)
+			min, max := light.ctRange()
+			ct := nearestCT(x, y, min, max)
+			delete(out, "xy")
+			delete(out, "hue")
+			delete(out, "sat")
+			out["ct"] = ct
+			return out, fmt.Sprintf("color as nearest white, ct %d (%dK)", ct, int(math.Round(1e6/float64(ct))))
+		}
+		if hasCT {
+			requested := intValue(out["ct"])
+			if ct := light.clampCT(requested); ct != requested {
+				out["ct"] = ct
+				return out, fmt.Sprintf("ct %d limited to %d", requested, ct)
+			}
+		}
+		return out, ""
+
+	default:
+		var dropped []string
+		for _, key := range []string{"xy", "ct", "hue", "sat"} {
+			if _, ok := out[key]; ok {
+				dropped = append(dropped, key)
+				delete(out, key)
+			}
+		}
+		if !light.dimmable() {
+			// On/off lights have no brightness and no transitions
+			delete(out, "transitiontime")
+			if _, ok := out["bri"]; ok {
+				dropped = append(dropped, "bri")
+				delete(out, "bri")
+			}
+		}
+		if len(dropped) == 0 {
+			return out, ""
+		}
+		if light.dimmable() {
+			return out, "brightness only"
+		}
+		return out, "on/off only"
+	}
+}
+
+// requestedXY returns the xy color point of a state holding xy or hue/sat;
+// a missing hue or sat is taken from the light's current state
+func requestedXY(light Light, state map[string]interface{}) (float64, float64) {
+	switch xy := state["xy"].(type) {
+	case [2]float64:
+		return xy[0], xy[1]
+	case []float64:
+		if len(xy) == 2 {
+			return xy[0], xy[1]
+		}
+	}
+
+	hue, sat := light.State.Hue, light.State.Sat
+	if v, ok := state["hue"]; ok {
+		hue = intValue(v)
+	}
+	if v, ok := state["sat"]; ok {
+		sat = intValue(v)
+	}
+	xy := hueSatToRGB(hue, sat).toXY()
+	return xy[0], xy[1]
+}
+
+// nearestCT returns the color temperature (mireds, within min-max) whose
+// point on the black body curve is closest to the xy color
+func nearestCT(x, y float64, min, max int) int {
+	best, bestDistance := min, math.MaxFloat64
+	for ct := min; ct <= max; ct++ {
+		cx, cy := ctToXY(ct)
+		if d := math.Hypot(x-cx, y-cy); d < bestDistance {
+			best, bestDistance = ct, d
+		}
+	}
+	return best
+}
+
+func intValue(v interface{}) int {
+	switch n := v.(type) {
+	case int:
+		return n
+	case float64:
+		return int(math.Round(n))
+	}
+	return 0
+}
+
+// xyValue reads an xy state value, as sent or as decoded from JSON
+func xyValue(v interface{}) ([2]float64, bool) {
+	switch xy := v.(type) {
+	case [2]float64:
+		return xy, true
+	case []float64:
+		if len(xy) == 2 {
+			return [2]float64{xy[0], xy[1]}, true
+		}
+	case []interface{}:
+		if len(xy) == 2 {
+			x, okX := xy[0].(float64)
+			y, okY := xy[1].(float64)
+			return [2]float64{x, y}, okX && okY
+		}
+	}
+	return [2]float64{}, false
+}
+
+// setLightsState sends a state to each of the lights, translated to what
+// each supports. It returns a note for every light whose state had to be
+// translated, and an error naming the lights the bridge refused. If the
+// policy denies any light, nothing is sent.
+func setLightsState(config *Config, lights map[string]Light, lightIDs []string, state map[string]interface{}) ([]string, error) {
+	notes, _, err := sendLightsState(config, lights, lightIDs, state)
+	return notes, err
+}
+
+// sendLightsState is setLightsState that also returns the IDs of the lights
+// the bridge accepted the state for
+func sendLightsState(config *Config, lights map[string]Light, lightIDs []string, state map[string]interface{}) ([]string, []string, error) {
+	// Check the policy for every light first, so a denied light doesn't
+	// leave the others half changed
+	for _, id := range lightIDs {
+		if light, ok := lights[id]; ok {
+			translated, _ := translateState(light, state)
+			if err := policyAllows(config, "PUT", "/lights/"+id+"/state", translated); err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+
+	var notes, set, failed []string
+	for _, id := range lightIDs {
+		light, ok := lights[id]
+		if !ok {
+			continue
+		}
+		translated, how := translateState(light, state)
+		if how != "" {
+			notes = append(notes, fmt.Sprintf("%s (%s): %s", light.Name, lightKind(light), how))
+		}
+		if err := setLightState(config, id, translated); err != nil {
+			var denied *PolicyError
+			if errors.As(err, &denied) {
+				// Only the rate budget can deny here, before anything was sent
+				return notes, set, err
+			}
+			failed = append(failed, fmt.Sprintf("%s: %v", light.Name, err))
+			continue
+		}
+		set = append(set, id)
+	}
+	if len(failed) > 0 {
+		return notes, set, fmt.Errorf("failed to set %d of %d lights: %s", len(failed), len(lightIDs), strings.Join(failed, "; "))
+	}
+	return notes, set, nil
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTranslateState(t *testing.T) {
+	extended := Light{Name: "Extended", Type: "Extended color light"}
+	colorOnly := Light{Name: "Color", Type: "Color light"}
+	ambiance := Light{Name: "Ambiance", Type: "Color temperature light"}
+	dimmable := Light{Name: "White", Type: "Dimmable light"}
+	plug := Light{Name: "Plug", Type: "On/Off plug-in unit"}
+
+	tests := []struct {
+		name  string
+		light Light
+		state map[string]interface{}
+		want  map[string]interface{}
+		note  string
+	}{
+		{"color unchanged", extended,
+			map[string]interface{}{"bri": 200, "xy": [2]float64{0.6, 0.3}},
+			map[string]interface{}{"bri": 200, "xy": [2]float64{0.6, 0.3}}, ""},
+		{"ct on a color light without whites", colorOnly,
+			map[string]interface{}{"ct": 153},
+			map[string]interface{}{"xy": [2]float64{0.3129, 0.3231}}, "ct 153 as xy"},
+		{"color as white", ambiance,
+			map[string]interface{}{"bri": 100, "xy": [2]float64{0.5, 0.41}},
+			map[string]interface{}{"bri": 100, "ct": 444}, "color as nearest white, ct 444 (2252K)"},
+		{"hue/sat as white", ambiance,
+			map[string]interface{}{"hue": 0, "sat": 0},
+			map[string]interface{}{"ct": 165}, "color as nearest white, ct 165 (6061K)"},
+		{"ct out of range", ambiance,
+			map[string]interface{}{"ct": 100},
+			map[string]interface{}{"ct": 153}, "ct 100 limited to 153"},
+		{"ct in range", ambiance,
+			map[string]interface{}{"ct": 366},
+			map[string]interface{}{"ct": 366}, ""},
+		{"brightness only", dimmable,
+			map[string]interface{}{"on": true, "bri": 100, "xy": [2]float64{0.6, 0.3}, "transitiontime": 4},
+			map[string]interface{}{"on": true, "bri": 100, "transitiontime": 4}, "brightness only"},
+		{"dimmable unchanged", dimmable,
+			map[string]interface{}{"bri": 100},
+			map[string]interface{}{"bri": 100}, ""},
+		{"on/off only", plug,
+			map[string]interface{}{"on": true, "bri": 100, "ct": 366, "transitiontime": 4},
+			map[string]interface{}{"on": true}, "on/off only"},
+		{"plug on", plug,
+			map[string]interface{}{"on": true},
+			map[string]interface{}{"on": true}, ""},
+	}
+	for _, tt := range tests {
+		got, note := translateState(tt.light, tt.state)
+		if !reflect.DeepEqual(got, tt.want) || note != tt.note {
+			t.Errorf("%s: translateState = %v, %q, want %v, %q", tt.name, got, note, tt.want, tt.note)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"math"
+	"strings"
+)
+
+// xyToLinearRGB converts a CIE xy color point to linear RGB (0-1), scaled so
+// the brightest channel is 1, using the wide-gamut conversion Philips
+// documents for Hue lights
+func xyToLinearRGB(x, y float64) (r, g, b float64) {
+	if y <= 0 {
+		return 1, 1, 1
+	}
+	bigY := 1.0
+	bigX := bigY / y * x
+	bigZ := bigY / y * (1 - x - y)
+
+	r = bigX*1.656492 - bigY*0.354851 - bigZ*0.255038
+	g = -bigX*0.707196 + bigY*1.655397 + bigZ*0.036152
+	b = bigX*0.051713 - bigY*0.121364 + bigZ*1.011530
+
+	r, g, b = math.Max(r, 0), math.Max(g, 0), math.Max(b, 0)
+	if m := math.Max(r, math.Max(g, b)); m > 0 {
+		r, g, b = r/m, g/m, b/m
+	}
+	return r, g, b
+}
+
+// rgbToHueSat converts linear RGB (0-1) to bridge hue (0-65535) and saturation (0-254)
+func rgbToHueSat(r, g, b float64) (int, int) {
+	max := math.Max(r, math.Max(g, b))
+	min := math.Min(r, math.Min(g, b))
+	delta := max - min
+
+	var h float64
+	switch {
+	case delta == 0:
+		h = 0
+	case max == r:
+		h = math.Mod((g-b)/delta, 6)
+	case max == g:
+		h = (b-r)/delta + 2
+	default:
+		h = (r-g)/delta + 4
+	}
+	h *= 60
+	if h < 0 {
+		h += 360
+	}
+
+	s := 0.0
+	if max > 0 {
+		s = delta / max
+	}
+	return int(math.Round(h / 360 * 65535)), int(math.Round(s * 254))
+}
+
+// hueSatDistance measures how far apart two hue/sat colors are, treating
+// them as points on a color wheel where saturation is the distance from white
+func hueSatDistance(h1, s1, h2, s2 int) float64 {
+	a1 := float64(h1) / 65536 * 2 * math.Pi
+	a2 := float64(h2) / 65536 * 2 * math.Pi
+	dx := float64(s1)*math.Cos(a1) - float64(s2)*math.Cos(a2)
+	dy := float64(s1)*math.Sin(a1) - float64(s2)*math.Sin(a2)
+	return math.Hypot(dx, dy)
+}
+
+// nearestPreset describes a light's color by the closest color preset name.
+// White-ambiance lights are described by color temperature alone, and lights
+// without color support have no color.
+func nearestPreset(state LightState) string {
+	switch strings.ToLower(state.ColorMode) {
+	case "ct":
+		switch {
+		case state.CT >= 370:
+			return "warm"
+		case state.CT <= 250:
+			return "cool"
+		default:
+			return "white"
+		}
+	case "xy":
+		if len(state.XY) == 2 {
+			h, s := rgbToHueSat(xyToLinearRGB(state.XY[0], state.XY[1]))
+			return nearestHueSatPreset(h, s)
+		}
+	case "hs":
+		return nearestHueSatPreset(state.Hue, state.Sat)
+	}
+	return ""
+}
+
+func nearestHueSatPreset(hue, sat int) string {
+	best := ""
+	bestDistance := math.MaxFloat64
+	presets := colorPresets()
+	for _, name := range sortedIDs(presets) {
+		c := presets[name].rgb()
+		h, s := rgbToHueSat(srgbToLinear(c.R), srgbToLinear(c.G), srgbToLinear(c.B))
+		if d := hueSatDistance(hue, sat, h, s); d < bestDistance {
+			best, bestDistance = name, d
+		}
+	}
+	return best
+}
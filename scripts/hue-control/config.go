@@ -0,0 +1,425 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/joho/godotenv"
+)
+
+// configKey describes a setting hue-control reads from its configuration
+type configKey struct {
+	Name        string // Environment variable / config file key, e.g. HUE_BRIDGE_IP
+	Flag        string // Global command-line flag, empty if the key has none
+	Secret      bool   // Redacted by 'config show'
+	Description string
+}
+
+// configKeys are the settings hue-control knows about, in the order 'config show' lists them
+var configKeys = []configKey{
+	{Name: "HUE_BRIDGE_IP", Flag: "bridge-ip", Description: "IP address of the Hue Bridge"},
+	{Name: "HUE_API_KEY", Flag: "api-key", Secret: true, Description: "Authenticated username/API key"},
+	{Name: "HUE_CLIENT_KEY", Secret: true, Description: "Entertainment streaming client key"},
+	{Name: "HUE_ENERGY_PRICE", Description: "Electricity price per kWh used by 'energy report'"},
+	{Name: "HUE_BRIGHTNESS_CURVE", Description: "How brightness percentages map to the bridge: linear (default), cie or gamma[:2.2]"},
+	{Name: "HUE_LATITUDE", Description: "Latitude for sun-based lighting, in degrees (north positive)"},
+	{Name: "HUE_LONGITUDE", Description: "Longitude for sun-based lighting, in degrees (east positive)"},
+	{Name: "HUE_POLICY", Description: "Safety policy file (default: policy.json in the user config directory, if present)"},
+}
+
+// Configuration sources, from highest to lowest precedence
+const (
+	sourceFlag    = "flag"
+	sourceEnv     = "environment"
+	sourceProject = "project .env"
+	sourceUser    = "user config"
+	sourceLegacy  = "legacy ~/.hue-config.json"
+)
+
+// configSetting is a resolved setting value and the source it came from
+type configSetting struct {
+	Value  string `json:"value"`
+	Source string `json:"source"`
+}
+
+// configOverrides holds values given as global flags, e.g. --bridge-ip
+var configOverrides = map[string]string{}
+
+// userConfigPath returns the per-user config file, e.g. ~/.config/hue-control/config.env
+func userConfigPath() (string, error) {
+	return userFilePath("config.env")
+}
+
+// userFilePath returns the path of a file in the per-user hue-control directory
+func userFilePath(name string) (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("cannot determine user config directory: %v", err)
+	}
+	return filepath.Join(dir, "hue-control", name), nil
+}
+
+// errLocked is returned by acquireFileLock when the lock is still held
+// after waiting
+var errLocked = errors.New("locked by another process")
+
+// acquireFileLock waits until it can create the lock file name in the
+// per-user directory and returns a function that releases it. A lock file
+// older than stale is assumed to be left over from a crash and taken over,
+// so the file is touched regularly for as long as the lock is held.
+func acquireFileLock(name string, wait, stale time.Duration) (func(), error) {
+	path, err := userFilePath(name)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+
+	deadline := time.Now().Add(wait)
+	for {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+		if err == nil {
+			fmt.Fprintf(f, "%d\n", os.Getpid())
+			f.Close()
+			return keepLock(path, stale), nil
+		}
+		if !os.IsExist(err) {
+			return nil, fmt.Errorf("failed to create lock %s: %v", path, err)
+		}
+		if info, err := os.Stat(path); err == nil && time.Since(info.ModTime()) > stale {
+			os.Remove(path)
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("%s: %w", path, errLocked)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+// keepLock refreshes a held lock file until the returned release function
+// is called
+func keepLock(path string, stale time.Duration) func() {
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(stale / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case now := <-ticker.C:
+				os.Chtimes(path, now, now)
+			}
+		}
+	}()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(done)
+			os.Remove(path)
+		})
+	}
+}
+
+// legacyConfigPath returns the path of the pre-.env JSON config file
+func legacyConfigPath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".hue-config.json"), nil
+}
+
+// readLegacyConfig reads ~/.hue-config.json into config keys
+func readLegacyConfig() map[string]string {
+	path, err := legacyConfigPath()
+	if err != nil {
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+
+	var legacyConfig struct {
+		BridgeIP string `json:"bridge_ip"`
+		APIKey   string `json:"api_key"`
+	}
+	if err := json.Unmarshal(data, &legacyConfig); err != nil {
+		return nil
+	}
+	return map[string]string{
+		"HUE_BRIDGE_IP": legacyConfig.BridgeIP,
+		"HUE_API_KEY":   legacyConfig.APIKey,
+	}
+}
+
+// resolveConfig looks up every known setting. Sources are consulted in order
+// of precedence: flags > environment > project .env > user config > legacy file.
+func resolveConfig() map[string]configSetting {
+	type layer struct {
+		source string
+		values map[string]string
+	}
+
+	project, _ := godotenv.Read(".env")
+	var user map[string]string
+	if path, err := userConfigPath(); err == nil {
+		user, _ = godotenv.Read(path)
+	}
+
+	layers := []layer{
+		{sourceFlag, configOverrides},
+		{sourceEnv, nil},
+		{sourceProject, project},
+		{sourceUser, user},
+		{sourceLegacy, readLegacyConfig()},
+	}
+
+	settings := map[string]configSetting{}
+	for _, key := range configKeys {
+		for _, l := range layers {
+			value := l.values[key.Name]
+			if l.source == sourceEnv {
+				value = os.Getenv(key.Name)
+			}
+			if value != "" {
+				settings[key.Name] = configSetting{Value: value, Source: l.source}
+				break
+			}
+		}
+	}
+	return settings
+}
+
+// configValue returns the resolved value of a setting, or "" if it is not set
+func configValue(name string) string {
+	return resolveConfig()[name].Value
+}
+
+func loadConfig() (*Config, error) {
+	settings := resolveConfig()
+
+	bridgeIP := settings["HUE_BRIDGE_IP"].Value
+	apiKey := settings["HUE_API_KEY"].Value
+
+	if bridgeIP == "" || apiKey == "" {
+		return nil, fmt.Errorf("configuration not found. Set HUE_BRIDGE_IP and HUE_API_KEY environment variables, or run 'hue-control setup'")
+	}
+
+	return &Config{
+		BridgeIP:  bridgeIP,
+		APIKey:    apiKey,
+		ClientKey: settings["HUE_CLIENT_KEY"].Value,
+		policy:    &policyCommand{},
+	}, nil
+}
+
+// saveConfig stores the connection details in the user config file,
+// keeping any other settings already there
+func saveConfig(config *Config) error {
+	values := map[string]string{
+		"HUE_BRIDGE_IP": config.BridgeIP,
+		"HUE_API_KEY":   config.APIKey,
+	}
+	if config.ClientKey != "" {
+		values["HUE_CLIENT_KEY"] = config.ClientKey
+	}
+	return updateUserConfig(values)
+}
+
+// updateUserConfig merges values into the user config file. Empty values
+// remove the key.
+func updateUserConfig(values map[string]string) error {
+	path, err := userConfigPath()
+	if err != nil {
+		return err
+	}
+
+	existing, err := godotenv.Read(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			return fmt.Errorf("failed to read %s: %v", path, err)
+		}
+		existing = map[string]string{}
+	}
+	for k, v := range values {
+		if v == "" {
+			delete(existing, k)
+		} else {
+			existing[k] = v
+		}
+	}
+
+	content, err := godotenv.Marshal(existing)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, []byte(content+"\n"), 0600); err != nil {
+		return err
+	}
+	// WriteFile keeps the mode of an existing file; the API key must stay private
+	return os.Chmod(path, 0600)
+}
+
+// findConfigKey matches a key given as HUE_BRIDGE_IP, bridge-ip or bridge_ip
+func findConfigKey(name string) (configKey, bool) {
+	normalized := strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+	for _, key := range configKeys {
+		if key.Name == normalized || key.Name == "HUE_"+normalized {
+			return key, true
+		}
+	}
+	return configKey{}, false
+}
+
+// parseGlobalFlags consumes global flags (e.g. --bridge-ip) that appear
+// before the command and returns the remaining arguments
+func parseGlobalFlags(args []string) []string {
+	for len(args) > 0 && strings.HasPrefix(args[0], "-") && args[0] != "-h" && args[0] != "--help" {
+		name := strings.TrimLeft(args[0], "-")
+		value := ""
+		hasValue := false
+		if i := strings.Index(name, "="); i >= 0 {
+			name, value, hasValue = name[:i], name[i+1:], true
+		}
+
+		var key *configKey
+		for i := range configKeys {
+			if configKeys[i].Flag != "" && configKeys[i].Flag == name {
+				key = &configKeys[i]
+			}
+		}
+		if key == nil {
+			fmt.Printf("Unknown global flag: %s\n", args[0])
+			os.Exit(1)
+		}
+
+		args = args[1:]
+		if !hasValue {
+			if len(args) == 0 {
+				fmt.Printf("Flag --%s needs a value\n", name)
+				os.Exit(1)
+			}
+			value, args = args[0], args[1:]
+		}
+		configOverrides[key.Name] = value
+	}
+	return args
+}
+
+// redact hides all but the first and last few characters of a secret
+func redact(value string) string {
+	if len(value) <= 8 {
+		return strings.Repeat("*", len(value))
+	}
+	return value[:4] + strings.Repeat("*", len(value)-8) + value[len(value)-4:]
+}
+
+func runConfig() {
+	if len(os.Args) < 3 {
+		printConfigUsage()
+		os.Exit(1)
+	}
+
+	switch os.Args[2] {
+	case "show":
+		runConfigShow()
+	case "set":
+		runConfigSet()
+	default:
+		fmt.Printf("Unknown config command: %s\n", os.Args[2])
+		printConfigUsage()
+		os.Exit(1)
+	}
+}
+
+func printConfigUsage() {
+	fmt.Println(`Usage:
+  hue-control config show [--json]
+  hue-control config set <key> <value>`)
+}
+
+func runConfigShow() {
+	showCmd := flag.NewFlagSet("config show", flag.ExitOnError)
+	jsonOutput := showCmd.Bool("json", false, "Output settings as JSON")
+	showCmd.Parse(os.Args[3:])
+
+	settings := resolveConfig()
+	for _, key := range configKeys {
+		if s, ok := settings[key.Name]; ok && key.Secret {
+			s.Value = redact(s.Value)
+			settings[key.Name] = s
+		}
+	}
+
+	if *jsonOutput {
+		out, _ := json.MarshalIndent(settings, "", "  ")
+		fmt.Println(string(out))
+		return
+	}
+
+	path, err := userConfigPath()
+	if err != nil {
+		path = err.Error()
+	}
+	fmt.Printf("User config file: %s\n", path)
+	fmt.Println("Precedence: flags > environment > project .env > user config > legacy ~/.hue-config.json")
+	fmt.Println("------------------------")
+	for _, key := range configKeys {
+		s, ok := settings[key.Name]
+		if !ok {
+			fmt.Printf("  %-22s (not set)\n", key.Name)
+			continue
+		}
+		fmt.Printf("  %-22s %s  [%s]\n", key.Name, s.Value, s.Source)
+	}
+}
+
+func runConfigSet() {
+	if len(os.Args) != 5 {
+		printConfigUsage()
+		os.Exit(1)
+	}
+
+	key, ok := findConfigKey(os.Args[3])
+	if !ok {
+		var names []string
+		for _, k := range configKeys {
+			names = append(names, k.Name)
+		}
+		fmt.Printf("Error: Unknown setting '%s'. Available: %s\n", os.Args[3], strings.Join(names, ", "))
+		os.Exit(1)
+	}
+	value := strings.TrimSpace(os.Args[4])
+
+	if err := updateUserConfig(map[string]string{key.Name: value}); err != nil {
+		fmt.Printf("Error saving config: %v\n", err)
+		os.Exit(1)
+	}
+
+	path, _ := userConfigPath()
+	if value == "" {
+		fmt.Printf("Removed %s from %s\n", key.Name, path)
+	} else {
+		fmt.Printf("Saved %s to %s\n", key.Name, path)
+	}
+
+	// Point out when a higher-precedence source hides the value just saved
+	if s, ok := resolveConfig()[key.Name]; ok && s.Source != sourceUser {
+		fmt.Printf("Note: %s is currently taken from the %s, which overrides the user config\n", key.Name, s.Source)
+	}
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// doctorCheck is the outcome of a single diagnostic check
+type doctorCheck struct {
+	Status string // PASS, WARN, FAIL or SKIP
+	Name   string
+	Detail string
+	Remedy string
+}
+
+// BridgeConfig holds the /config attributes the doctor reports on
+type BridgeConfig struct {
+	Name       string `json:"name"`
+	BridgeID   string `json:"bridgeid"`
+	ModelID    string `json:"modelid"`
+	SWVersion  string `json:"swversion"`
+	APIVersion string `json:"apiversion"`
+	// Only present when the request is made with a valid API key
+	IPAddress string `json:"ipaddress"`
+}
+
+func runDoctor() {
+	var checks []doctorCheck
+	add := func(status, name, detail, remedy string) {
+		checks = append(checks, doctorCheck{Status: status, Name: name, Detail: detail, Remedy: remedy})
+	}
+
+	settings := resolveConfig()
+	config, err := loadConfig()
+	if err != nil {
+		add("FAIL", "Configuration", err.Error(), "Run 'hue-control setup', or set HUE_BRIDGE_IP and HUE_API_KEY")
+	} else {
+		add("PASS", "Configuration", fmt.Sprintf("bridge IP %s (from %s), API key from %s",
+			config.BridgeIP, settings["HUE_BRIDGE_IP"].Source, settings["HUE_API_KEY"].Source), "")
+	}
+
+	checks = append(checks, checkLegacyConfig())
+
+	if config != nil {
+		checks = append(checks, checkBridge(config)...)
+	}
+
+	failed := false
+	for _, c := range checks {
+		line := fmt.Sprintf("[%s] %s", c.Status, c.Name)
+		if c.Detail != "" {
+			line += ": " + c.Detail
+		}
+		fmt.Println(line)
+		if c.Remedy != "" && c.Status != "PASS" {
+			fmt.Printf("       -> %s\n", c.Remedy)
+		}
+		if c.Status == "FAIL" {
+			failed = true
+		}
+	}
+
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// checkBridge runs the checks that need a configured bridge. Each check is
+// skipped when the one it depends on has failed.
+func checkBridge(config *Config) []doctorCheck {
+	var checks []doctorCheck
+	address := net.JoinHostPort(config.BridgeIP, "443")
+
+	conn, err := net.DialTimeout("tcp", address, 3*time.Second)
+	if err != nil {
+		return append(checks,
+			doctorCheck{Status: "FAIL", Name: "Bridge reachable", Detail: err.Error(),
+				Remedy: "Check the bridge is powered and on this network. Its IP may have changed: find it at https://discovery.meethue.com and run 'hue-control config set bridge-ip <ip>'"},
+			doctorCheck{Status: "SKIP", Name: "TLS handshake"},
+			doctorCheck{Status: "SKIP", Name: "API key"},
+		)
+	}
+	conn.Close()
+	checks = append(checks, doctorCheck{Status: "PASS", Name: "Bridge reachable", Detail: address})
+
+	tlsConn, err := tls.DialWithDialer(&net.Dialer{Timeout: 3 * time.Second}, "tcp", address, &tls.Config{
+		InsecureSkipVerify: true, // Hue Bridge uses self-signed certs
+	})
+	if err != nil {
+		return append(checks,
+			doctorCheck{Status: "FAIL", Name: "TLS handshake", Detail: err.Error(),
+				Remedy: "Something other than a Hue Bridge may be answering on this IP. Check the address with 'hue-control config show'"},
+			doctorCheck{Status: "SKIP", Name: "API key"},
+		)
+	}
+	certName := ""
+	if certs := tlsConn.ConnectionState().PeerCertificates; len(certs) > 0 {
+		certName = certs[0].Subject.CommonName
+	}
+	tlsConn.Close()
+	checks = append(checks, doctorCheck{Status: "PASS", Name: "TLS handshake", Detail: "certificate " + certName})
+
+	var bridge BridgeConfig
+	if err := bridgeGet(config, "/config", &bridge); err != nil {
+		return append(checks, doctorCheck{Status: "FAIL", Name: "API key", Detail: err.Error(), Remedy: "Run 'hue-control setup' to create a new key"})
+	}
+	// The bridge answers /config for unknown keys too, but only with the public attributes
+	if bridge.IPAddress == "" {
+		return append(checks, doctorCheck{Status: "FAIL", Name: "API key", Detail: "the bridge does not recognize this key (it may have been revoked)",
+			Remedy: "Run 'hue-control setup' to create a new key"})
+	}
+	checks = append(checks, doctorCheck{Status: "PASS", Name: "API key", Detail: fmt.Sprintf("accepted by '%s'", bridge.Name)})
+	checks = append(checks, doctorCheck{Status: "PASS", Name: "Bridge firmware",
+		Detail: fmt.Sprintf("model %s, software %s, API %s", bridge.ModelID, bridge.SWVersion, bridge.APIVersion)})
+
+	lights, err := getLights(config)
+	if err != nil {
+		checks = append(checks, doctorCheck{Status: "FAIL", Name: "Lights", Detail: err.Error()})
+	} else {
+		var unreachable []string
+		for _, id := range sortedIDs(lights) {
+			if !lights[id].State.Reachable {
+				unreachable = append(unreachable, fmt.Sprintf("%s [%s]", lights[id].Name, id))
+			}
+		}
+		if len(unreachable) > 0 {
+			checks = append(checks, doctorCheck{Status: "WARN", Name: "Lights reachable",
+				Detail: fmt.Sprintf("%d of %d unreachable: %s", len(unreachable), len(lights), strings.Join(unreachable, ", ")),
+				Remedy: "Check the wall switch is on and the bulb is within range of another light or the bridge"})
+		} else {
+			checks = append(checks, doctorCheck{Status: "PASS", Name: "Lights reachable", Detail: fmt.Sprintf("all %d lights", len(lights))})
+		}
+	}
+
+	groups, err := getGroups(config)
+	if err != nil {
+		checks = append(checks, doctorCheck{Status: "FAIL", Name: "Group names", Detail: err.Error()})
+	} else {
+		seen := map[string][]string{}
+		for _, id := range sortedIDs(groups) {
+			key := strings.ToLower(groups[id].Name)
+			seen[key] = append(seen[key], id)
+		}
+		var duplicates []string
+		for _, id := range sortedIDs(groups) {
+			ids := seen[strings.ToLower(groups[id].Name)]
+			if len(ids) > 1 && ids[0] == id {
+				duplicates = append(duplicates, fmt.Sprintf("'%s' (ids %s)", groups[id].Name, strings.Join(ids, ", ")))
+			}
+		}
+		if len(duplicates) > 0 {
+			checks = append(checks, doctorCheck{Status: "WARN", Name: "Group names", Detail: "duplicates: " + strings.Join(duplicates, "; "),
+				Remedy: "Commands using --room pick the lowest ID. Rename one with 'hue-control room rename <id> <new-name>'"})
+		} else {
+			checks = append(checks, doctorCheck{Status: "PASS", Name: "Group names", Detail: fmt.Sprintf("%d groups, all unique", len(groups))})
+		}
+	}
+
+	return checks
+}
+
+// checkLegacyConfig reports on ~/.hue-config.json: whether it is still in
+// use and whether its permissions expose the API key
+func checkLegacyConfig() doctorCheck {
+	path, err := legacyConfigPath()
+	if err != nil {
+		return doctorCheck{Status: "SKIP", Name: "Legacy config", Detail: err.Error()}
+	}
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return doctorCheck{Status: "PASS", Name: "Legacy config", Detail: "not present"}
+	}
+	if err != nil {
+		return doctorCheck{Status: "WARN", Name: "Legacy config", Detail: err.Error()}
+	}
+
+	var used []string
+	for name, s := range resolveConfig() {
+		if s.Source == sourceLegacy {
+			used = append(used, name)
+		}
+	}
+	sort.Strings(used)
+
+	detail := fmt.Sprintf("%s is present but overridden by newer settings", path)
+	remedy := "Delete it to avoid confusion"
+	if len(used) > 0 {
+		detail = fmt.Sprintf("%s still provides %s", path, strings.Join(used, ", "))
+		remedy = "Move the values with 'hue-control config set <key> <value>', then delete the file"
+	}
+	if perm := info.Mode().Perm(); perm&0077 != 0 {
+		detail += fmt.Sprintf(", and is readable by other users (mode %o)", perm)
+		remedy = fmt.Sprintf("Run 'chmod 600 %s' now. %s", path, remedy)
+	}
+	return doctorCheck{Status: "WARN", Name: "Legacy config", Detail: detail, Remedy: remedy}
+}
@@ -0,0 +1,472 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// lightWattage is the power draw of a light model at full brightness and
+// while switched off (but still powered)
+type lightWattage struct {
+	Max     float64
+	Standby float64
+}
+
+// modelWattage holds published power figures for common Hue models, keyed by model ID
+var modelWattage = map[string]lightWattage{
+	// Color A19/E27 bulbs
+	"LCT001": {8.5, 0.4}, "LCT007": {9, 0.4}, "LCT010": {9.5, 0.4},
+	"LCT014": {9.5, 0.4}, "LCT015": {9.5, 0.4}, "LCT016": {9.5, 0.4},
+	"LCA001": {9, 0.3}, "LCA002": {9, 0.3}, "LCA003": {9, 0.3},
+	"LCA005": {9.5, 0.3}, "LCA006": {9.5, 0.3}, "LCA007": {13.5, 0.3},
+	// Color GU10 spots and candles
+	"LCT003": {6.5, 0.4}, "LCG002": {5.7, 0.3}, "LCT012": {6, 0.4}, "LCE002": {6.2, 0.3},
+	// White ambiance
+	"LTW001": {9.5, 0.4}, "LTW004": {9.5, 0.4}, "LTW010": {9.5, 0.4},
+	"LTW012": {5.2, 0.4}, "LTW015": {9.5, 0.4}, "LTA001": {8, 0.3},
+	"LTG002": {5, 0.3}, "LTW013": {5.5, 0.4},
+	// White
+	"LWB004": {9, 0.4}, "LWB006": {9, 0.4}, "LWB010": {9, 0.4},
+	"LWB014": {9, 0.4}, "LWA001": {9.5, 0.2}, "LWA003": {9.5, 0.2},
+	// Lightstrips, gradient and lamps
+	"LST001": {20.5, 0.5}, "LST002": {20, 0.5}, "LST004": {20, 0.5},
+	"LCL001": {37.5, 0.5}, "LCX001": {24, 0.5}, "LCX002": {24, 0.5},
+	"LCX003": {24, 0.5}, "LCX004": {20, 0.5},
+	"LLC010": {6, 0.4}, "LLC011": {8, 0.4}, "LLC012": {8, 0.4},
+	"LLC013": {7.5, 0.4}, "LLC020": {10, 0.4}, "LCT024": {7, 0.4},
+}
+
+// typeWattage is the fallback for models not in modelWattage
+var typeWattage = map[string]lightWattage{
+	"Extended color light":    {9, 0.4},
+	"Color light":             {8, 0.4},
+	"Color temperature light": {9, 0.4},
+	"Dimmable light":          {9, 0.4},
+	// The load of a smart plug is unknown; count only the plug itself
+	"On/Off plug-in unit": {0.4, 0.4},
+}
+
+// lightPower estimates the current power draw of a light in watts. Output
+// (and so power) is assumed proportional to the bridge brightness value.
+// Unreachable lights are most likely switched off at the wall and draw nothing.
+func lightPower(light Light) float64 {
+	if !light.State.Reachable {
+		return 0
+	}
+	w, ok := modelWattage[light.ModelID]
+	if !ok {
+		if w, ok = typeWattage[light.Type]; !ok {
+			w = lightWattage{Max: 9, Standby: 0.4}
+		}
+	}
+	if !light.State.On {
+		return w.Standby
+	}
+	bri := light.State.Bri
+	if bri == 0 {
+		// Lights without dimming report no brightness
+		bri = 254
+	}
+	return w.Standby + (w.Max-w.Standby)*float64(bri)/254
+}
+
+// energyLedger stores accumulated energy per day and light
+type energyLedger struct {
+	Days map[string]map[string]*lightEnergy `json:"days"` // date -> light ID -> usage
+}
+
+// lightEnergy is the energy one light used on one day
+type lightEnergy struct {
+	Name string  `json:"name"`
+	Room string  `json:"room,omitempty"`
+	KWh  float64 `json:"kwh"`
+}
+
+// energyLockStale is how old the tracker's lock file may get before it is
+// assumed to be left over from a crashed tracker
+const energyLockStale = 2 * time.Minute
+
+func energyLedgerPath() (string, error) {
+	return userFilePath("energy.json")
+}
+
+func loadEnergyLedger() (*energyLedger, error) {
+	ledger := &energyLedger{Days: map[string]map[string]*lightEnergy{}}
+	path, err := energyLedgerPath()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return ledger, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, ledger); err != nil {
+		return nil, fmt.Errorf("invalid energy ledger %s: %v", path, err)
+	}
+	if ledger.Days == nil {
+		ledger.Days = map[string]map[string]*lightEnergy{}
+	}
+	return ledger, nil
+}
+
+// save writes the ledger through a temporary file so a crash never leaves it half written
+func (l *energyLedger) save() error {
+	path, err := energyLedgerPath()
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(l, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// splitByDay splits the time from start to end at local midnight and
+// returns how much of it falls on each date
+func splitByDay(start, end time.Time) map[string]time.Duration {
+	days := map[string]time.Duration{}
+	for start.Before(end) {
+		y, m, d := start.Date()
+		next := time.Date(y, m, d+1, 0, 0, 0, 0, start.Location())
+		if next.After(end) {
+			next = end
+		}
+		days[start.Format("2006-01-02")] += next.Sub(start)
+		start = next
+	}
+	return days
+}
+
+// lightRooms maps each light ID to the name of the room it belongs to
+func lightRooms(groups map[string]Group) map[string]string {
+	rooms := map[string]string{}
+	for _, id := range sortedIDs(groups) {
+		if groups[id].Type != "Room" {
+			continue
+		}
+		for _, lightID := range groups[id].Lights {
+			rooms[lightID] = groups[id].Name
+		}
+	}
+	return rooms
+}
+
+func runEnergy() {
+	if len(os.Args) < 3 {
+		printEnergyUsage()
+		os.Exit(1)
+	}
+
+	switch os.Args[2] {
+	case "now":
+		runEnergyNow()
+	case "track":
+		runEnergyTrack()
+	case "report":
+		runEnergyReport()
+	default:
+		fmt.Printf("Unknown energy command: %s\n", os.Args[2])
+		printEnergyUsage()
+		os.Exit(1)
+	}
+}
+
+func printEnergyUsage() {
+	fmt.Println(`Usage:
+  hue-control energy now
+  hue-control energy track [--interval 1m]
+  hue-control energy report [--month YYYY-MM] [--price <per kWh>]`)
+}
+
+func runEnergyNow() {
+	config, err := loadConfig()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	lights, err := getLights(config)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	groups, err := getGroups(config)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	rooms := lightRooms(groups)
+
+	roomWatts := map[string]float64{}
+	total := 0.0
+	fmt.Println("Estimated power per light:")
+	fmt.Println("------------------------")
+	for _, id := range sortedIDs(lights) {
+		w := lightPower(lights[id])
+		total += w
+		room := rooms[id]
+		if room == "" {
+			room = "Not in a room"
+		}
+		roomWatts[room] += w
+		fmt.Printf("  [%s] %s (%s) - %.1f W\n", id, lights[id].Name, lights[id].ModelID, w)
+	}
+
+	fmt.Println("\nEstimated power per room:")
+	fmt.Println("------------------------")
+	names := make([]string, 0, len(roomWatts))
+	for name := range roomWatts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Printf("  %s - %.1f W\n", name, roomWatts[name])
+	}
+	fmt.Printf("\nTotal: %.1f W\n", total)
+}
+
+func runEnergyTrack() {
+	trackCmd := flag.NewFlagSet("energy track", flag.ExitOnError)
+	interval := trackCmd.Duration("interval", time.Minute, "How often to sample light state")
+	trackCmd.Parse(os.Args[3:])
+
+	if *interval < 5*time.Second {
+		fmt.Println("Error: Interval must be at least 5s")
+		os.Exit(1)
+	}
+
+	config, err := loadConfig()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	// A second tracker would count every light twice
+	release, err := acquireFileLock("energy.lock", 0, energyLockStale)
+	if errors.Is(err, errLocked) {
+		fmt.Printf("Error: another 'energy track' is already running (%v)\n", err)
+		os.Exit(1)
+	}
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	defer release()
+
+	ledger, err := loadEnergyLedger()
+	if err != nil {
+		release()
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	path, _ := energyLedgerPath()
+	fmt.Printf("Tracking energy every %s into %s (Ctrl-C to stop)\n", *interval, path)
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	ticker := time.NewTicker(*interval)
+	defer ticker.Stop()
+
+	// Each sample's power is charged for the time until the next sample. Gaps
+	// much longer than the interval (e.g. the machine slept) are capped.
+	var lastPower map[string]float64
+	var lastNames, lastRooms map[string]string
+	lastSample := time.Now()
+
+	charge := func(now time.Time) {
+		if lastPower == nil {
+			return
+		}
+		elapsed := now.Sub(lastSample)
+		if elapsed > 2**interval {
+			elapsed = 2 * *interval
+		}
+		for day, d := range splitByDay(lastSample, lastSample.Add(elapsed)) {
+			if ledger.Days[day] == nil {
+				ledger.Days[day] = map[string]*lightEnergy{}
+			}
+			for id, w := range lastPower {
+				entry := ledger.Days[day][id]
+				if entry == nil {
+					entry = &lightEnergy{}
+					ledger.Days[day][id] = entry
+				}
+				entry.Name, entry.Room = lastNames[id], lastRooms[id]
+				entry.KWh += w * d.Hours() / 1000
+			}
+		}
+		if err := ledger.save(); err != nil {
+			fmt.Printf("Error saving energy ledger: %v\n", err)
+		}
+	}
+
+	for {
+		now := time.Now()
+		charge(now)
+
+		lights, err := getLights(config)
+		var groups map[string]Group
+		if err == nil {
+			groups, err = getGroups(config)
+		}
+		if err != nil {
+			// Don't charge anything for the time we couldn't see the lights
+			fmt.Printf("Error reading bridge state: %v\n", err)
+			lastPower = nil
+		} else {
+			lastPower = map[string]float64{}
+			lastNames = map[string]string{}
+			lastRooms = lightRooms(groups)
+			for id, light := range lights {
+				lastPower[id] = lightPower(light)
+				lastNames[id] = light.Name
+			}
+		}
+		lastSample = now
+
+		select {
+		case <-ticker.C:
+		case <-stop:
+			// Charge the time since the last sample too
+			charge(time.Now())
+			fmt.Println("Stopped tracking")
+			return
+		}
+	}
+}
+
+func runEnergyReport() {
+	reportCmd := flag.NewFlagSet("energy report", flag.ExitOnError)
+	month := reportCmd.String("month", time.Now().Format("2006-01"), "Month to show daily totals for (YYYY-MM)")
+	price := reportCmd.Float64("price", -1, "Electricity price per kWh (default: HUE_ENERGY_PRICE)")
+	reportCmd.Parse(os.Args[3:])
+
+	if _, err := time.Parse("2006-01", *month); err != nil {
+		fmt.Println("Error: Month must be in YYYY-MM format")
+		os.Exit(1)
+	}
+	if *price < 0 {
+		*price = 0
+		if v := configValue("HUE_ENERGY_PRICE"); v != "" {
+			p, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				fmt.Printf("Error: HUE_ENERGY_PRICE '%s' is not a number\n", v)
+				os.Exit(1)
+			}
+			*price = p
+		}
+	}
+
+	ledger, err := loadEnergyLedger()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	if len(ledger.Days) == 0 {
+		fmt.Println("No energy recorded yet. Run 'hue-control energy track' to start recording.")
+		return
+	}
+
+	cost := func(kwh float64) string {
+		if *price == 0 {
+			return ""
+		}
+		return fmt.Sprintf(" (%.2f)", kwh**price)
+	}
+
+	days := make([]string, 0, len(ledger.Days))
+	for day := range ledger.Days {
+		days = append(days, day)
+	}
+	sort.Strings(days)
+
+	fmt.Printf("Daily totals for %s:\n", *month)
+	fmt.Println("------------------------")
+	monthTotal := 0.0
+	roomTotals := map[string]float64{}
+	lightTotals := map[string]float64{} // by light ID, as names need not be unique
+	lightNames := map[string]string{}
+	for _, day := range days {
+		if !strings.HasPrefix(day, *month) {
+			continue
+		}
+		dayTotal := 0.0
+		for id, e := range ledger.Days[day] {
+			dayTotal += e.KWh
+			room := e.Room
+			if room == "" {
+				room = "Not in a room"
+			}
+			roomTotals[room] += e.KWh
+			lightTotals[id] += e.KWh
+			lightNames[id] = e.Name
+			if e.Room != "" {
+				lightNames[id] = fmt.Sprintf("%s (%s)", e.Name, e.Room)
+			}
+		}
+		monthTotal += dayTotal
+		fmt.Printf("  %s  %.3f kWh%s\n", day, dayTotal, cost(dayTotal))
+	}
+
+	// printTotals lists totals by key, largest first; labels names the keys
+	// that aren't shown as they are
+	printTotals := func(title string, totals map[string]float64, labels map[string]string) {
+		keys := make([]string, 0, len(totals))
+		for key := range totals {
+			keys = append(keys, key)
+		}
+		sort.Slice(keys, func(i, j int) bool { return totals[keys[i]] > totals[keys[j]] })
+		fmt.Printf("\n%s:\n", title)
+		fmt.Println("------------------------")
+		for _, key := range keys {
+			label := key
+			if name, ok := labels[key]; ok {
+				label = name
+			}
+			fmt.Printf("  %-24s %.3f kWh%s\n", label, totals[key], cost(totals[key]))
+		}
+	}
+	printTotals(fmt.Sprintf("By room for %s", *month), roomTotals, nil)
+	printTotals(fmt.Sprintf("By light for %s", *month), lightTotals, lightNames)
+
+	monthly := map[string]float64{}
+	for _, day := range days {
+		for _, e := range ledger.Days[day] {
+			monthly[day[:7]] += e.KWh
+		}
+	}
+	fmt.Println("\nMonthly totals:")
+	fmt.Println("------------------------")
+	months := make([]string, 0, len(monthly))
+	for m := range monthly {
+		months = append(months, m)
+	}
+	sort.Strings(months)
+	for _, m := range months {
+		fmt.Printf("  %s  %.3f kWh%s\n", m, monthly[m], cost(monthly[m]))
+	}
+
+	fmt.Printf("\nTotal for %s: %.3f kWh%s\n", *month, monthTotal, cost(monthTotal))
+}
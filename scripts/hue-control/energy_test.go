@@ -0,0 +1,39 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSplitByDay(t *testing.T) {
+	oslo, err := time.LoadLocation("Europe/Oslo")
+	if err != nil {
+		t.Skip("no time zone data:", err)
+	}
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2026, 3, day, hour, minute, 0, 0, oslo)
+	}
+
+	tests := []struct {
+		name       string
+		start, end time.Time
+		want       map[string]time.Duration
+	}{
+		{"within a day", at(10, 12, 0), at(10, 12, 1), map[string]time.Duration{"2026-03-10": time.Minute}},
+		{"across midnight", at(10, 23, 59), at(11, 0, 1), map[string]time.Duration{
+			"2026-03-10": time.Minute, "2026-03-11": time.Minute}},
+		{"ends at midnight", at(10, 23, 0), at(11, 0, 0), map[string]time.Duration{"2026-03-10": time.Hour}},
+		{"several days", at(10, 12, 0), at(12, 12, 0), map[string]time.Duration{
+			"2026-03-10": 12 * time.Hour, "2026-03-11": 24 * time.Hour, "2026-03-12": 12 * time.Hour}},
+		// The clocks go forward on 29 March, a day of 23 hours
+		{"daylight saving time", at(28, 12, 0), at(30, 12, 0), map[string]time.Duration{
+			"2026-03-28": 12 * time.Hour, "2026-03-29": 23 * time.Hour, "2026-03-30": 12 * time.Hour}},
+		{"empty", at(10, 12, 0), at(10, 12, 0), map[string]time.Duration{}},
+	}
+	for _, tt := range tests {
+		if got := splitByDay(tt.start, tt.end); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: splitByDay = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
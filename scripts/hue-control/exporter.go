@@ -0,0 +1,306 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// requestDurationBuckets are the upper bounds (seconds) of the bridge request latency histogram
+var requestDurationBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// requestStats accumulates latency and errors of bridge requests per HTTP method
+type requestStats struct {
+	mu      sync.Mutex
+	count   map[string]uint64
+	sum     map[string]float64
+	buckets map[string][]uint64
+	errors  map[string]uint64
+}
+
+// bridgeRequestStats records every request made through getHTTPClient
+var bridgeRequestStats = &requestStats{
+	count:   map[string]uint64{},
+	sum:     map[string]float64{},
+	buckets: map[string][]uint64{},
+	errors:  map[string]uint64{},
+}
+
+func (s *requestStats) observe(method string, seconds float64, failed bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.buckets[method] == nil {
+		s.buckets[method] = make([]uint64, len(requestDurationBuckets))
+	}
+	s.count[method]++
+	s.sum[method] += seconds
+	for i, le := range requestDurationBuckets {
+		if seconds <= le {
+			s.buckets[method][i]++
+		}
+	}
+	if failed {
+		s.errors[method]++
+	}
+}
+
+// instrumentedTransport times every bridge request and counts failures:
+// connection errors, non-2xx responses and v1 responses listing errors,
+// which the bridge sends with status 200
+type instrumentedTransport struct {
+	base http.RoundTripper
+}
+
+func (t *instrumentedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := t.base.RoundTrip(req)
+	failed := err != nil || resp.StatusCode >= 300
+	if !failed {
+		body, readErr := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		resp.Body = io.NopCloser(bytes.NewReader(body))
+		failed = readErr != nil || hasBridgeErrors(body)
+	}
+	bridgeRequestStats.observe(req.Method, time.Since(start).Seconds(), failed)
+	return resp, err
+}
+
+// hasBridgeErrors reports whether a v1 response is a list with error entries
+func hasBridgeErrors(body []byte) bool {
+	var results []bridgeResult
+	if json.Unmarshal(body, &results) != nil {
+		return false
+	}
+	for _, r := range results {
+		if r.Error != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// metricsWriter builds the Prometheus text exposition format
+type metricsWriter struct {
+	b        strings.Builder
+	declared map[string]bool
+}
+
+func (w *metricsWriter) metric(name, typ, help string, labels map[string]string, value float64) {
+	if w.declared == nil {
+		w.declared = map[string]bool{}
+	}
+	if !w.declared[name] {
+		fmt.Fprintf(&w.b, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+		w.declared[name] = true
+	}
+	w.sample(name, labels, value)
+}
+
+// sample writes a sample line without HELP/TYPE, for histogram series
+func (w *metricsWriter) sample(name string, labels map[string]string, value float64) {
+	w.b.WriteString(name)
+	if len(labels) > 0 {
+		keys := make([]string, 0, len(labels))
+		for k := range labels {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		var pairs []string
+		for _, k := range keys {
+			pairs = append(pairs, fmt.Sprintf("%s=\"%s\"", k, escapeLabel(labels[k])))
+		}
+		w.b.WriteString("{" + strings.Join(pairs, ",") + "}")
+	}
+	fmt.Fprintf(&w.b, " %g\n", value)
+}
+
+func escapeLabel(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s)
+}
+
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// collectBridgeMetrics reads lights, groups and sensors from the bridge and
+// renders them as gauges
+func collectBridgeMetrics(config *Config) (string, error) {
+	lights, err := getLights(config)
+	if err != nil {
+		return "", err
+	}
+	groups, err := getGroups(config)
+	if err != nil {
+		return "", err
+	}
+	sensors, err := getSensors(config)
+	if err != nil {
+		return "", err
+	}
+
+	w := &metricsWriter{}
+	for _, id := range sortedIDs(lights) {
+		light := lights[id]
+		labels := map[string]string{"id": id, "name": light.Name}
+		w.metric("hue_light_on", "gauge", "Whether the light is on (1) or off (0).", labels, boolValue(light.State.On))
+	}
+	for _, id := range sortedIDs(lights) {
+		light := lights[id]
+		labels := map[string]string{"id": id, "name": light.Name}
+		w.metric("hue_light_brightness_percent", "gauge", "Brightness of the light in percent.", labels, float64(briToPercent(light.State.Bri)))
+	}
+	for _, id := range sortedIDs(lights) {
+		light := lights[id]
+		labels := map[string]string{"id": id, "name": light.Name}
+		w.metric("hue_light_reachable", "gauge", "Whether the bridge can reach the light.", labels, boolValue(light.State.Reachable))
+	}
+
+	for _, id := range sortedIDs(groups) {
+		group := groups[id]
+		labels := map[string]string{"id": id, "name": group.Name, "type": group.Type}
+		w.metric("hue_room_any_on", "gauge", "Whether any light in the room or zone is on.", labels, boolValue(group.State.AnyOn))
+	}
+	for _, id := range sortedIDs(groups) {
+		group := groups[id]
+		labels := map[string]string{"id": id, "name": group.Name, "type": group.Type}
+		w.metric("hue_room_all_on", "gauge", "Whether all lights in the room or zone are on.", labels, boolValue(group.State.AllOn))
+	}
+
+	var readings []SensorReading
+	for _, id := range sortedIDs(sensors) {
+		readings = append(readings, newSensorReading(id, sensors[id]))
+	}
+	sensorLabels := func(r SensorReading) map[string]string {
+		return map[string]string{"id": r.ID, "name": r.Name, "type": r.Type}
+	}
+	for _, r := range readings {
+		if r.TemperatureC != nil {
+			w.metric("hue_sensor_temperature_celsius", "gauge", "Temperature reported by the sensor.", sensorLabels(r), *r.TemperatureC)
+		}
+	}
+	for _, r := range readings {
+		if r.Lux != nil {
+			w.metric("hue_sensor_light_lux", "gauge", "Light level reported by the sensor, in lux.", sensorLabels(r), *r.Lux)
+		}
+	}
+	for _, r := range readings {
+		if r.Presence != nil {
+			w.metric("hue_sensor_presence", "gauge", "Whether the motion sensor currently detects presence.", sensorLabels(r), boolValue(*r.Presence))
+		}
+	}
+	for _, r := range readings {
+		if r.Battery != nil {
+			w.metric("hue_sensor_battery_percent", "gauge", "Battery level of the sensor in percent.", sensorLabels(r), float64(*r.Battery))
+		}
+	}
+
+	return w.b.String(), nil
+}
+
+// writeRequestMetrics renders the bridge request counters collected so far
+func writeRequestMetrics(w *metricsWriter) {
+	s := bridgeRequestStats
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	methods := make([]string, 0, len(s.count))
+	for m := range s.count {
+		methods = append(methods, m)
+	}
+	sort.Strings(methods)
+
+	if len(methods) > 0 {
+		fmt.Fprintf(&w.b, "# HELP hue_bridge_request_duration_seconds Latency of requests to the Hue Bridge.\n# TYPE hue_bridge_request_duration_seconds histogram\n")
+	}
+	for _, m := range methods {
+		for i, le := range requestDurationBuckets {
+			w.sample("hue_bridge_request_duration_seconds_bucket", map[string]string{"method": m, "le": fmt.Sprintf("%g", le)}, float64(s.buckets[m][i]))
+		}
+		w.sample("hue_bridge_request_duration_seconds_bucket", map[string]string{"method": m, "le": "+Inf"}, float64(s.count[m]))
+		w.sample("hue_bridge_request_duration_seconds_sum", map[string]string{"method": m}, s.sum[m])
+		w.sample("hue_bridge_request_duration_seconds_count", map[string]string{"method": m}, float64(s.count[m]))
+	}
+	for _, m := range methods {
+		w.metric("hue_bridge_request_errors_total", "counter", "Requests to the Hue Bridge that failed, returned a non-2xx status or reported errors.",
+			map[string]string{"method": m}, float64(s.errors[m]))
+	}
+}
+
+func runExporter() {
+	exporterCmd := flag.NewFlagSet("exporter", flag.ExitOnError)
+	listen := exporterCmd.String("listen", ":9742", "Address to serve /metrics on")
+	interval := exporterCmd.Duration("interval", 30*time.Second, "How often to read state from the bridge")
+	exporterCmd.Parse(os.Args[2:])
+
+	if *interval < time.Second {
+		fmt.Println("Error: Interval must be at least 1s")
+		os.Exit(1)
+	}
+
+	config, err := loadConfig()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Scrapes are served from the last snapshot so they never hit the bridge
+	var mu sync.RWMutex
+	var snapshot string
+	var lastSuccess time.Time
+	up := false
+
+	refresh := func() {
+		text, err := collectBridgeMetrics(config)
+		mu.Lock()
+		defer mu.Unlock()
+		if err != nil {
+			fmt.Printf("Error reading bridge state: %v\n", err)
+			up = false
+			return
+		}
+		snapshot, up, lastSuccess = text, true, time.Now()
+	}
+
+	refresh()
+	go func() {
+		for range time.Tick(*interval) {
+			refresh()
+		}
+	}()
+
+	http.HandleFunc("/metrics", func(rw http.ResponseWriter, r *http.Request) {
+		mu.RLock()
+		w := &metricsWriter{}
+		w.b.WriteString(snapshot)
+		w.metric("hue_up", "gauge", "Whether the last read from the bridge succeeded.", nil, boolValue(up))
+		if !lastSuccess.IsZero() {
+			w.metric("hue_last_refresh_timestamp_seconds", "gauge", "Unix time of the last successful read from the bridge.", nil, float64(lastSuccess.Unix()))
+		}
+		mu.RUnlock()
+		writeRequestMetrics(w)
+
+		rw.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		fmt.Fprint(rw, w.b.String())
+	})
+	http.HandleFunc("/", func(rw http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(rw, "<html><body><h1>hue-control exporter</h1><a href=\"/metrics\">Metrics</a></body></html>\n")
+	})
+
+	fmt.Printf("Serving metrics on %s/metrics (refreshing every %s)\n", *listen, *interval)
+	if err := http.ListenAndServe(*listen, nil); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+}
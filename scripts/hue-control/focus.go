@@ -0,0 +1,193 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// focusPhase is one work or break period of a focus timer
+type focusPhase struct {
+	Name       string
+	Duration   time.Duration
+	Color      map[string]interface{}
+	Brightness int // percent
+}
+
+func runFocus() {
+	focusCmd := flag.NewFlagSet("focus", flag.ExitOnError)
+	room := focusCmd.String("room", "", "Room to use for the timer")
+	work := focusCmd.Duration("work", 25*time.Minute, "Length of each work period")
+	breakTime := focusCmd.Duration("break", 5*time.Minute, "Length of each break")
+	cycles := focusCmd.Int("cycles", 4, "Number of work periods")
+	color := focusCmd.String("color", "cool", "Color preset or hex color during work")
+	brightness := focusCmd.Int("brightness", 100, "Brightness percentage during work")
+	breakColor := focusCmd.String("break-color", "warm", "Color preset or hex color during breaks")
+	breakBrightness := focusCmd.Int("break-brightness", 50, "Brightness percentage during breaks")
+	endingColor := focusCmd.String("ending-color", "#ffb000", "Color the lights shift toward at the end of work periods")
+	ending := focusCmd.Duration("ending", 3*time.Minute, "How long before the end of a work period the shift starts (0 to disable)")
+	noFlash := focusCmd.Bool("no-flash", false, "Don't flash the lights between periods")
+	focusCmd.Parse(os.Args[2:])
+
+	if *room == "" {
+		fmt.Println("Error: --room is required")
+		os.Exit(1)
+	}
+	if *cycles < 1 {
+		fmt.Println("Error: Cycles must be at least 1")
+		os.Exit(1)
+	}
+	if *work < time.Minute || *breakTime < 0 {
+		fmt.Println("Error: Work periods must be at least 1m and breaks can't be negative")
+		os.Exit(1)
+	}
+	if *ending < 0 || *ending >= *work {
+		fmt.Println("Error: --ending must be shorter than --work")
+		os.Exit(1)
+	}
+	for _, b := range []int{*brightness, *breakBrightness} {
+		if b < 1 || b > 100 {
+			fmt.Println("Error: Brightness must be between 1 and 100")
+			os.Exit(1)
+		}
+	}
+
+	workPhase := focusPhase{Name: "Work", Duration: *work, Brightness: *brightness}
+	breakPhase := focusPhase{Name: "Break", Duration: *breakTime, Brightness: *breakBrightness}
+	var endingState map[string]interface{}
+	for _, c := range []struct {
+		spec  string
+		state *map[string]interface{}
+	}{{*color, &workPhase.Color}, {*breakColor, &breakPhase.Color}, {*endingColor, &endingState}} {
+		var err error
+		if *c.state, err = colorState(c.spec); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	config, err := loadConfig()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	lights, err := getLights(config)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	target, lightIDs, err := notificationLights(config, lights, notification{Room: *room})
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	// Unreachable lights can neither follow the timer nor be restored
+	var ids []string
+	for _, id := range lightIDs {
+		if lights[id].State.Reachable {
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 {
+		fmt.Printf("Error: no reachable lights in '%s'\n", target)
+		os.Exit(1)
+	}
+
+	// Ctrl-C ends the timer early but still restores the room
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	sleep := func(d time.Duration) bool {
+		select {
+		case <-stop:
+			return false
+		case <-time.After(d):
+			return true
+		}
+	}
+	// Each period, shift and flash counts as one command for the policy's
+	// rate budget
+	current := config
+	begin := func() { current = beginPolicyCommand(config) }
+	apply := func(state map[string]interface{}) {
+		if _, err := setLightsState(current, lights, ids, state); err != nil {
+			fmt.Printf("Warning: %v\n", err)
+		}
+	}
+
+	completed := runFocusTimer(target, *cycles, workPhase, breakPhase, endingState, *ending, !*noFlash, begin, apply, sleep)
+
+	if _, err := restoreLights(beginPolicyCommand(config), lights, ids, false); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	if completed {
+		fmt.Printf("Finished %d focus cycles, restored %s\n", *cycles, target)
+	} else {
+		fmt.Printf("Stopped early, restored %s\n", target)
+	}
+}
+
+// runFocusTimer steps through the work periods and the breaks between them.
+// During the last part of each work period (ending) the lights slowly shift
+// to endingColor. begin starts a new command for the policy's rate budget
+// before each period, shift and flash. It returns false if sleep was
+// interrupted.
+func runFocusTimer(target string, cycles int, work, rest focusPhase, endingColor map[string]interface{}, ending time.Duration, flash bool, begin func(), apply func(map[string]interface{}), sleep func(time.Duration) bool) bool {
+	phaseState := func(p focusPhase) map[string]interface{} {
+		state := roomStateRequest(percentToBri(p.Brightness), p.Color)
+		state["transitiontime"] = 10
+		return state
+	}
+
+	for cycle := 1; cycle <= cycles; cycle++ {
+		fmt.Printf("%s %d/%d in %s until %s\n", work.Name, cycle, cycles, target, time.Now().Add(work.Duration).Format("15:04"))
+		begin()
+		apply(phaseState(work))
+		if !sleep(work.Duration - ending) {
+			return false
+		}
+		if ending > 0 {
+			// One long transition; the bridge counts it in 100ms steps
+			shift := roomStateRequest(percentToBri(work.Brightness), endingColor)
+			shift["transitiontime"] = int(min(ending/(100*time.Millisecond), 65535))
+			begin()
+			apply(shift)
+			if !sleep(ending) {
+				return false
+			}
+		}
+
+		if flash {
+			begin()
+			focusFlash(apply)
+		}
+		if cycle == cycles || rest.Duration == 0 {
+			continue
+		}
+		fmt.Printf("%s in %s until %s\n", rest.Name, target, time.Now().Add(rest.Duration).Format("15:04"))
+		begin()
+		apply(phaseState(rest))
+		if !sleep(rest.Duration) {
+			return false
+		}
+		if flash {
+			begin()
+			focusFlash(apply)
+		}
+	}
+	return true
+}
+
+// focusFlash blinks the lights twice to mark the end of a period
+func focusFlash(apply func(map[string]interface{})) {
+	for i := 0; i < 2; i++ {
+		apply(map[string]interface{}{"on": false, "transitiontime": 0})
+		time.Sleep(400 * time.Millisecond)
+		apply(map[string]interface{}{"on": true, "transitiontime": 0})
+		time.Sleep(400 * time.Millisecond)
+	}
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+func runLight() {
+	if len(os.Args) < 3 {
+		printLightUsage()
+		os.Exit(1)
+	}
+
+	switch os.Args[2] {
+	case "search":
+		runLightSearch()
+	case "rename":
+		runLightRename()
+	case "identify":
+		runLightIdentify()
+	case "delete":
+		runLightDelete()
+	default:
+		fmt.Printf("Unknown light command: %s\n", os.Args[2])
+		printLightUsage()
+		os.Exit(1)
+	}
+}
+
+func printLightUsage() {
+	fmt.Println(`Usage:
+  hue-control light search [--serial <s1,s2,...>] [--timeout 70s]
+  hue-control light rename <light> <new-name>
+  hue-control light identify <light> [--once]
+  hue-control light delete <light>`)
+}
+
+func runLightSearch() {
+	searchCmd := flag.NewFlagSet("light search", flag.ExitOnError)
+	serials := searchCmd.String("serial", "", "Comma-separated serial numbers of lights that were reset or are not found otherwise")
+	timeout := searchCmd.Duration("timeout", 70*time.Second, "How long to wait for the search to finish")
+	searchCmd.Parse(os.Args[3:])
+
+	config, err := loadConfig()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	var body interface{}
+	if *serials != "" {
+		var ids []string
+		for _, s := range strings.Split(*serials, ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				ids = append(ids, strings.ToUpper(s))
+			}
+		}
+		body = map[string]interface{}{"deviceid": ids}
+	}
+
+	if _, err := bridgeWrite(config, "POST", "/lights", body); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Println("Searching for new lights (this takes about a minute)...")
+
+	// The bridge reports lastscan "active" while the search is running and
+	// the scan's finishing timestamp once it is done
+	deadline := time.Now().Add(*timeout)
+	var found map[string]json.RawMessage
+	for {
+		time.Sleep(2 * time.Second)
+
+		if err := bridgeGet(config, "/lights/new", &found); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		var lastScan string
+		json.Unmarshal(found["lastscan"], &lastScan)
+		if lastScan != "active" {
+			break
+		}
+		if time.Now().After(deadline) {
+			fmt.Println("Search still running on the bridge; showing lights found so far")
+			break
+		}
+	}
+	delete(found, "lastscan")
+
+	if len(found) == 0 {
+		fmt.Println("No new lights found")
+		return
+	}
+
+	fmt.Println("New lights:")
+	for _, id := range sortedIDs(found) {
+		var light struct {
+			Name string `json:"name"`
+		}
+		json.Unmarshal(found[id], &light)
+		fmt.Printf("  [%s] %s\n", id, light.Name)
+	}
+}
+
+func runLightRename() {
+	if len(os.Args) != 5 {
+		printLightUsage()
+		os.Exit(1)
+	}
+	lightName, newName := os.Args[3], os.Args[4]
+
+	config, err := loadConfig()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	lights, err := getLights(config)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	lightID, ok := findLight(lights, lightName)
+	if !ok {
+		fmt.Printf("Error: light '%s' not found\n", lightName)
+		os.Exit(1)
+	}
+	if strings.TrimSpace(newName) == "" {
+		fmt.Println("Error: light name must not be empty")
+		os.Exit(1)
+	}
+	for _, id := range sortedIDs(lights) {
+		if id != lightID && strings.EqualFold(lights[id].Name, newName) {
+			fmt.Printf("Error: a light named '%s' already exists (id %s)\n", lights[id].Name, id)
+			os.Exit(1)
+		}
+	}
+
+	if _, err := bridgeWrite(config, "PUT", "/lights/"+lightID, map[string]interface{}{"name": newName}); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Renamed '%s' to '%s'\n", lights[lightID].Name, newName)
+}
+
+func runLightIdentify() {
+	identifyCmd := flag.NewFlagSet("light identify", flag.ExitOnError)
+	once := identifyCmd.Bool("once", false, "Blink a single time instead of for 15 seconds")
+	args := parseInterspersed(identifyCmd, os.Args[3:])
+
+	if len(args) != 1 {
+		printLightUsage()
+		os.Exit(1)
+	}
+
+	config, err := loadConfig()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	lights, err := getLights(config)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	lightID, ok := findLight(lights, args[0])
+	if !ok {
+		fmt.Printf("Error: light '%s' not found\n", args[0])
+		os.Exit(1)
+	}
+
+	// "select" breathes once, "lselect" keeps breathing for 15 seconds
+	alert := "lselect"
+	if *once {
+		alert = "select"
+	}
+
+	if _, err := bridgeWrite(config, "PUT", "/lights/"+lightID+"/state", map[string]interface{}{"alert": alert}); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Blinking '%s' (id %s)\n", lights[lightID].Name, lightID)
+}
+
+func runLightDelete() {
+	if len(os.Args) != 4 {
+		printLightUsage()
+		os.Exit(1)
+	}
+
+	config, err := loadConfig()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	lights, err := getLights(config)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	lightID, ok := findLight(lights, os.Args[3])
+	if !ok {
+		fmt.Printf("Error: light '%s' not found\n", os.Args[3])
+		os.Exit(1)
+	}
+
+	if _, err := bridgeWrite(config, "DELETE", "/lights/"+lightID, nil); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Deleted light '%s' (id %s)\n", lights[lightID].Name, lightID)
+}
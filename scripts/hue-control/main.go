@@ -5,30 +5,39 @@ import (
 	"bytes"
 	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
-	"path/filepath"
 	"strings"
 	"time"
-
-	"github.com/joho/godotenv"
 )
 
 // Config holds the Hue Bridge connection details
 type Config struct {
-	BridgeIP string
-	APIKey   string
+	BridgeIP  string
+	APIKey    string
+	ClientKey string // Entertainment streaming key, empty for keys created before it was requested
+
+	policy *policyCommand // the command writes are counted as by the policy's rate budget
 }
 
 // Group represents a Hue group (room/zone)
 type Group struct {
-	Name   string     `json:"name"`
-	Type   string     `json:"type"`
-	Lights []string   `json:"lights"`
-	Action GroupState `json:"action"`
+	Name   string       `json:"name"`
+	Type   string       `json:"type"`
+	Class  string       `json:"class,omitempty"`
+	Lights []string     `json:"lights"`
+	Action GroupState   `json:"action"`
+	State  GroupSummary `json:"state"`
+}
+
+// GroupSummary reports whether any or all lights of a group are on
+type GroupSummary struct {
+	AllOn bool `json:"all_on"`
+	AnyOn bool `json:"any_on"`
 }
 
 // GroupState represents the state of a group
@@ -39,35 +48,76 @@ type GroupState struct {
 	Sat int  `json:"sat,omitempty"`
 }
 
-// ColorPreset maps color names to Hue and Saturation values
-var ColorPresets = map[string][2]int{
-	// Format: "name": {hue (0-65535), saturation (0-254)}
-	"red":    {0, 254},
-	"orange": {5000, 254},
-	"yellow": {10000, 254},
-	"green":  {25500, 254},
-	"cyan":   {35000, 254},
-	"blue":   {46920, 254},
-	"purple": {50000, 254},
-	"pink":   {56100, 254},
-	"warm":   {8000, 200}, // Warm white
-	"cool":   {34000, 50}, // Cool white
-	"white":  {0, 0},      // Pure white (no color)
-}
-
 // Light represents a Hue light
 type Light struct {
-	Name  string     `json:"name"`
-	State LightState `json:"state"`
+	Name         string            `json:"name"`
+	Type         string            `json:"type"`
+	ModelID      string            `json:"modelid"`
+	State        LightState        `json:"state"`
+	Capabilities LightCapabilities `json:"capabilities"`
+}
+
+// LightCapabilities describes what a light can do
+type LightCapabilities struct {
+	Control struct {
+		ColorGamutType string `json:"colorgamuttype,omitempty"`
+		CT             *struct {
+			Min int `json:"min"`
+			Max int `json:"max"`
+		} `json:"ct,omitempty"`
+	} `json:"control"`
+}
+
+// supportsCT reports whether the light can set a color temperature
+func (l Light) supportsCT() bool {
+	return l.Capabilities.Control.CT != nil || l.Type == "Color temperature light" || l.Type == "Extended color light"
+}
+
+// supportsColor reports whether the light can show colors, not just whites
+func (l Light) supportsColor() bool {
+	return l.Capabilities.Control.ColorGamutType != "" || l.Type == "Color light" || l.Type == "Extended color light"
+}
+
+// dimmable reports whether the light has a brightness, unlike on/off plugs
+func (l Light) dimmable() bool {
+	return !strings.HasPrefix(l.Type, "On/Off")
+}
+
+// ctRange returns the color temperatures (mireds) the light supports
+func (l Light) ctRange() (int, int) {
+	if r := l.Capabilities.Control.CT; r != nil && r.Max > 0 {
+		return r.Min, r.Max
+	}
+	return 153, 500
+}
+
+// clampCT limits a color temperature (mireds) to the range the light supports
+func (l Light) clampCT(ct int) int {
+	min, max := l.ctRange()
+	if ct < min {
+		return min
+	}
+	if ct > max {
+		return max
+	}
+	return ct
 }
 
 // LightState represents the state of a light
 type LightState struct {
-	On  bool `json:"on"`
-	Bri int  `json:"bri,omitempty"`
+	On        bool      `json:"on"`
+	Bri       int       `json:"bri,omitempty"`
+	Hue       int       `json:"hue,omitempty"`
+	Sat       int       `json:"sat,omitempty"`
+	XY        []float64 `json:"xy,omitempty"`
+	CT        int       `json:"ct,omitempty"`
+	ColorMode string    `json:"colormode,omitempty"` // "hs", "xy" or "ct"; empty for lights without color
+	Reachable bool      `json:"reachable"`
 }
 
 func main() {
+	os.Args = append(os.Args[:1], parseGlobalFlags(os.Args[1:])...)
+
 	if len(os.Args) < 2 {
 		printUsage()
 		os.Exit(1)
@@ -78,6 +128,36 @@ func main() {
 	switch command {
 	case "setup":
 		runSetup()
+	case "config":
+		runConfig()
+	case "doctor":
+		runDoctor()
+	case "status":
+		runStatus()
+	case "exporter":
+		runExporter()
+	case "energy":
+		runEnergy()
+	case "notify":
+		runNotify()
+	case "webhook":
+		runWebhook()
+	case "focus":
+		runFocus()
+	case "mcp":
+		runMCP()
+	case "say":
+		runSay()
+	case "policy":
+		runPolicy()
+	case "preset":
+		runPreset()
+	case "sun":
+		runSun()
+	case "adaptive":
+		runAdaptive()
+	case "away":
+		runAway()
 	case "list":
 		runList()
 	case "set":
@@ -86,6 +166,14 @@ func main() {
 		runOn()
 	case "off":
 		runOff()
+	case "sensors":
+		runSensors()
+	case "rule":
+		runRule()
+	case "room":
+		runRoom()
+	case "light":
+		runLight()
 	case "help", "-h", "--help":
 		printUsage()
 	default:
@@ -99,123 +187,272 @@ func printUsage() {
 	fmt.Println(`Hue Control - Philips Hue Light Controller
 
 Usage:
-  hue-control <command> [options]
+  hue-control [--bridge-ip <ip>] [--api-key <key>] <command> [options]
 
 Commands:
-  setup       Configure Hue Bridge connection (saves to .env)
+  setup       Configure Hue Bridge connection (saves to the user config file)
+  config      Show or change configuration: config show | config set <key> <value>
+  doctor      Diagnose connection, API key, firmware, unreachable lights and config problems
   list        List available rooms/groups
+  status      Show the actual state of every light, grouped by room
   set         Set brightness for lights
   on          Turn all lights on
   off         Turn all lights off
+  sensors     List sensors with battery, last update and current reading
+  exporter    Serve light, room and sensor state as Prometheus metrics
+  energy      Estimate power use: energy now | energy track | energy report
+  notify      Flash a room or light, then restore its exact previous state
+  focus       Pomodoro timer: focus light for work, relaxed light for breaks
+  webhook     Receive signed HTTP webhooks and turn them into light actions
+  say         Control lights with a phrase like "dim the kitchen to 30 percent"
+  policy      Show the safety policy enforced on every change: policy show
+  mcp         Serve typed tools to AI agents over the Model Context Protocol (stdio)
+  preset      Manage color presets: preset list | preset add <name> | preset remove <name>
+  sun         Show sunrise, sunset, twilight and solar noon for a day
+  adaptive    Follow the sun: cool and bright at midday, warm and dim after sunset
+  away        Simulate presence while you're away, or record history for it
+  light       Manage lights: search, rename, identify, delete
+  room        Manage rooms and zones: create, rename, add-light, remove-light, delete
+  rule        Manage bridge rules: rule list | rule create <file> | rule delete <name>
   help        Show this help message
 
+Setup Command Options:
+  --bridge-ip <ip>     Bridge IP address; skips the interactive prompt so no TTY is needed
+  --timeout <duration> How long to keep retrying while waiting for the link button (default: 60s)
+
 Set Command Options:
   --room <name>        Room name to control (default: "all")
   --brightness <0-100> Brightness percentage (default: 100)
   --hue <0-65535>      Hue value for color (optional)
   --sat <0-254>        Saturation value for color (optional)
-  --color <name>       Color preset: red, orange, yellow, green, cyan, blue, purple, pink, warm, cool, white
+  --color <name>       Color preset: red, orange, yellow, green, cyan, blue, purple, pink, warm, cool, white,
+                       or one of your own (see 'preset list')
+  --palette <name>     Spread a palette across the lights: sunset, ocean, forest, fire, aurora, candy,
+                       a palette from palettes.json, or a list of colors like "red,#00ff00,blue"
+  --gradient <a..b>    Blend colors evenly across the lights, e.g. red..blue or red..yellow..blue
+  --random             Assign palette or gradient colors to the lights in random order
+  --light <name>       Control a single light instead of a room
+  --points <colors>    Segment colors for a gradient light (with --light), e.g. "#f00,#0f0,#00f"
+  --gradient-mode <m>  With --points: interpolated, mirrored, random or segmented (as the light supports)
+  --verify             Re-read the lights after the transition and report any that didn't
+                       reach the requested state; exit status 2 on a partial failure
+
+Status Command Options:
+  --room <name>        Only show this room
+  --json               Output as JSON
+
+Sensors Command Options:
+  --json               Output readings as JSON (temperature in °C, light level in lux)
+
+Light Commands:
+  light search [--serial <s1,s2,...>] [--timeout 70s]
+  light rename <light> <new-name>
+  light identify <light> [--once]
+  light delete <light>
+  Lights may be given by name or ID.
+
+Room Commands:
+  room create <name> [--type Room|Zone] [--class <class>] [--lights <a,b,...>]
+  room rename <room> <new-name>
+  room add-light <room> <light> [<light>...]
+  room remove-light <room> <light> [<light>...]
+  room delete <room>
+  Lights may be given by name or ID. Duplicate room names are refused.
+
+Exporter Command Options:
+  --listen <addr>      Address to serve /metrics on (default: ":9742")
+  --interval <dur>     How often to read state from the bridge (default: 30s)
+
+Energy Commands:
+  energy now                       Estimated current power per light and room
+  energy track [--interval 1m]     Keep running and record kWh per light and day
+  energy report [--month YYYY-MM] [--price <per kWh>]
+                                   Daily, per-room and monthly totals
+
+Notify Command Options:
+  --room <name>        Room to flash (or --light <name> for a single light)
+  --color <name|hex>   Color to flash (default: keep each light's color)
+  --count <n>          Number of flashes (default: 3)
+  --on, --off <dur>    Flash and pause durations (default: 500ms each)
+  --brightness <1-100> Brightness of the flash (default: 100)
+  --alert              Use the bridge's 15 second breathing alert instead of flashing
+  --wait <dur>         How long to queue behind a running notification (default: 1m)
+
+Focus Command Options:
+  --room <name>              Room to use (required)
+  --work, --break <dur>      Work and break lengths (default: 25m, 5m)
+  --cycles <n>               Number of work periods (default: 4)
+  --color <name|hex>         Work color (default: cool), --brightness (default: 100)
+  --break-color <name|hex>   Break color (default: warm), --break-brightness (default: 50)
+  --ending <dur>             Shift toward --ending-color (default: amber) this long
+                             before each work period ends (default: 3m, 0 to disable)
+  --no-flash                 Don't flash the lights between periods
+  The room's original state is restored when the timer ends or on Ctrl-C.
+
+Say Command Options:
+  say [--dry-run] "<text>"
+  Understands rooms (typos allowed) or "all lights", on/off, percentages,
+  half/full, brighter/dimmer (a bit, a lot, by N%), color presets, hex
+  colors and "<name> scene". Ambiguous phrases are rejected.
+  --dry-run            Only show what was understood
+
+Policy:
+  policy show          Show the active safety policy and which limits apply now
+  The policy file ($XDG_CONFIG_HOME/hue-control/policy.json, or HUE_POLICY)
+  is checked before every change sent to the bridge, by every command.
+
+Webhook Command Options:
+  --config <file>      YAML file mapping routes to actions (required)
+  --listen <addr>      Address to listen on (default: from the config, or :9743)
+  --dry-run            Log the actions requests would trigger without changing lights
+  Requests must be POSTs signed with an HMAC-SHA256 of the body in
+  X-Hub-Signature-256 ("sha256=<hex>"); unsigned requests are rejected.
+
+Preset Commands:
+  preset list [--json]
+  preset add <name> (--hex <#rrggbb> | --xy <x,y> | --ct <153-500> | --hue <h> --sat <s>) [--brightness <0-100>]
+  preset remove <name>
+  Custom presets are saved to $XDG_CONFIG_HOME/hue-control/presets.json and
+  override built-ins of the same name. A preset's brightness applies unless
+  --brightness is given.
+
+Sun Command Options:
+  --date <YYYY-MM-DD>  Day to show (default: today)
+  --lat, --lon <deg>   Location (default: HUE_LATITUDE, HUE_LONGITUDE)
+  --json               Output as JSON
+
+Time Specs:
+  Schedules accept a clock time "HH:MM" or a sun time with an optional offset:
+  sunrise, sunset, civil-dawn, civil-dusk, solar-noon, e.g. sunset-30m, sunrise+1h15m.
+  Sun times use the local calendar day, so they follow DST changes. On days an
+  event does not happen (polar day or night) windows using it are skipped.
+
+Adaptive Command Options:
+  --rooms <a,b,...>    Rooms to adjust (required)
+  --lat, --lon <deg>   Location for the sun position (default: HUE_LATITUDE, HUE_LONGITUDE)
+  --interval <dur>     How often to adjust the lights (default: 1m)
+  --transition <dur>   Fade time for each adjustment (default: 10s)
+  --min-brightness, --max-brightness <1-100>
+                       Brightness at night and at midday (default: 35, 100)
+  --warmest, --coolest <K>
+                       Color temperature at night and at midday (default: 2200, 5000)
+  --resume-after <dur> Resume a manually changed room after this long (default: 2h)
+  --once               Adjust once and exit
+  Only lights that are on are touched. A room changed by hand is paused until
+  it is switched off or --resume-after has passed.
+
+Away Command Options:
+  --rooms <a,b,...>    Rooms to switch on and off (required)
+  --template <file>    JSON on/off windows per room, e.g. {"rooms": {"Kitchen": [{"on": "sunset-30m", "off": "19:30"}]}}
+  --history <file>     Learn typical evening times from this history (default: recorded by 'away record')
+  --jitter <dur>       Random shift applied to every on/off time (default: 20m)
+  --log <file>         Also append every action to this file
+  --dry-run            Print today's plan without switching lights
+  away record [--interval 5m]   Record which rooms are on, to learn from later
+
+Rule Create Options:
+  --name <name>        Rule name (overrides the 'name:' line in the file)
+  --dry-run            Print the compiled bridge rule without uploading it
+
+Rule Definition File:
+  name: Hallway motion
+  when sensor 'Hall Motion' presence is true and daylight is false,
+  set room Hallway to 30% warm
 
 Configuration:
-  Authentication defaults to reading from a .env file or environment variables:
+  Settings are read from, in order of precedence:
+    1. Global flags (--bridge-ip, --api-key)
+    2. Environment variables
+    3. A .env file in the current directory
+    4. The user config file ($XDG_CONFIG_HOME/hue-control/config.env)
+    5. The legacy ~/.hue-config.json
+  Settings:
   - HUE_BRIDGE_IP
   - HUE_API_KEY
+  - HUE_CLIENT_KEY (optional, entertainment streaming key generated by setup)
+  - HUE_ENERGY_PRICE (optional, price per kWh for 'energy report')
+  - HUE_BRIGHTNESS_CURVE (optional, linear, cie or gamma[:2.2]; how percentages map to bridge brightness)
+  - HUE_LATITUDE, HUE_LONGITUDE (optional, location for 'adaptive', 'sun' and sun times)
+  Use 'config show' to see each value and where it came from.
 
 Examples:
   hue-control setup
+  hue-control setup --bridge-ip 192.168.1.100 --timeout 60s
+  hue-control config show
+  hue-control doctor
+  hue-control config set bridge-ip 192.168.1.101
   hue-control list
+  hue-control status --room "Living Room"
+  hue-control sensors --json
+  hue-control exporter --listen :9742 --interval 15s
+  hue-control energy report --price 0.30
+  hue-control notify --room Office --color red --count 3
+  hue-control focus --room Office --work 25m --break 5m --cycles 4
+  hue-control webhook --config hooks.yaml
+  hue-control say "dim the living room to 30 percent and make it warm"
+  hue-control mcp
+  hue-control preset add candle --ct 454 --brightness 30
+  hue-control sun --date 2026-12-21
+  hue-control adaptive --rooms "Living Room,Office" --lat 51.5 --lon -0.12
+  hue-control away --rooms "Living Room,Kitchen,Bedroom" --log away.log
+  hue-control room create "Reading Nook" --type Zone --class Reading --lights "Floor Lamp,Desk"
+  hue-control rule create hallway.rule
   hue-control set --brightness 50
   hue-control set --room "Living Room" --brightness 75
   hue-control set --color blue
-  hue-control set --room "Bedroom" --color warm --brightness 60`)
-}
-
-func loadConfig() (*Config, error) {
-	// Try loading from .env file, but don't fail if it doesn't exist
-	// (we might be using system env vars)
-	_ = godotenv.Load()
-
-	bridgeIP := os.Getenv("HUE_BRIDGE_IP")
-	apiKey := os.Getenv("HUE_API_KEY")
-
-	// Fallback to legacy config file if env vars are missing
-	if bridgeIP == "" || apiKey == "" {
-		home, err := os.UserHomeDir()
-		if err == nil {
-			legacyPath := filepath.Join(home, ".hue-config.json")
-			if data, err := os.ReadFile(legacyPath); err == nil {
-				var legacyConfig struct {
-					BridgeIP string `json:"bridge_ip"`
-					APIKey   string `json:"api_key"`
-				}
-				if err := json.Unmarshal(data, &legacyConfig); err == nil {
-					if bridgeIP == "" {
-						bridgeIP = legacyConfig.BridgeIP
-					}
-					if apiKey == "" {
-						apiKey = legacyConfig.APIKey
-					}
-				}
-			}
-		}
-	}
-
-	if bridgeIP == "" || apiKey == "" {
-		return nil, fmt.Errorf("configuration not found. Set HUE_BRIDGE_IP and HUE_API_KEY environment variables, or run 'hue-control setup'")
-	}
-
-	return &Config{
-		BridgeIP: bridgeIP,
-		APIKey:   apiKey,
-	}, nil
-}
-
-func saveConfig(config *Config) error {
-	// Check for existing .env to append/update, or create new
-	envPath := ".env"
-
-	// Simple .env writing (overwrites logic for simplicity in this tailored tool)
-	content := fmt.Sprintf("HUE_BRIDGE_IP=%s\nHUE_API_KEY=%s\n", config.BridgeIP, config.APIKey)
-	return os.WriteFile(envPath, []byte(content), 0600)
+  hue-control set --room "Bedroom" --color warm --brightness 60
+  hue-control set --room "Living Room" --palette sunset --random
+  hue-control set --room "Living Room" --gradient red..blue
+  hue-control set --light "TV Strip" --points "#ff0000,#00ff00,#0000ff" --gradient-mode mirrored
+  hue-control set --room Office --color warm --verify`)
 }
 
 // getHTTPClient returns an HTTP client configured for Hue Bridge communication
 func getHTTPClient() *http.Client {
 	return &http.Client{
 		Timeout: 10 * time.Second,
-		Transport: &http.Transport{
-			TLSClientConfig: &tls.Config{
-				InsecureSkipVerify: true, // Hue Bridge uses self-signed certs
+		Transport: &instrumentedTransport{
+			base: &http.Transport{
+				TLSClientConfig: &tls.Config{
+					InsecureSkipVerify: true, // Hue Bridge uses self-signed certs
+				},
 			},
 		},
 	}
 }
 
 func runSetup() {
-	reader := bufio.NewReader(os.Stdin)
+	setupCmd := flag.NewFlagSet("setup", flag.ExitOnError)
+	bridgeIPFlag := setupCmd.String("bridge-ip", "", "Hue Bridge IP address (skips the interactive prompt)")
+	timeout := setupCmd.Duration("timeout", 60*time.Second, "How long to wait for the link button to be pressed")
+	setupCmd.Parse(os.Args[2:])
 
-	fmt.Print("Enter Hue Bridge IP address: ")
-	bridgeIP, _ := reader.ReadString('\n')
-	bridgeIP = strings.TrimSpace(bridgeIP)
+	bridgeIP := strings.TrimSpace(*bridgeIPFlag)
+	if bridgeIP == "" {
+		reader := bufio.NewReader(os.Stdin)
+		fmt.Print("Enter Hue Bridge IP address: ")
+		bridgeIP, _ = reader.ReadString('\n')
+		bridgeIP = strings.TrimSpace(bridgeIP)
+	}
 
 	if bridgeIP == "" {
 		fmt.Println("Error: Bridge IP is required")
 		os.Exit(1)
 	}
 
-	fmt.Println("\nPress the button on your Hue Bridge, then press Enter here...")
-	reader.ReadString('\n')
+	fmt.Printf("\nPress the button on your Hue Bridge (waiting up to %s)...\n", *timeout)
 
-	// Create user/API key
-	apiKey, err := createUser(bridgeIP)
+	// Create user/API key, retrying until the link button is pressed
+	apiKey, clientKey, err := waitForLink(bridgeIP, *timeout)
 	if err != nil {
 		fmt.Printf("Error: %v\n", err)
 		os.Exit(1)
 	}
 
 	config := &Config{
-		BridgeIP: bridgeIP,
-		APIKey:   apiKey,
+		BridgeIP:  bridgeIP,
+		APIKey:    apiKey,
+		ClientKey: clientKey,
 	}
 
 	if err := saveConfig(config); err != nil {
@@ -223,49 +460,79 @@ func runSetup() {
 		os.Exit(1)
 	}
 
-	fmt.Printf("\nSuccess! Configuration saved to .env\n")
+	path, _ := userConfigPath()
+	fmt.Printf("\nSuccess! Configuration saved to %s\n", path)
+	if s := resolveConfig()["HUE_API_KEY"]; s.Source != sourceUser {
+		fmt.Printf("Note: HUE_API_KEY from the %s overrides the saved key. Remove it there to use the new one.\n", s.Source)
+	}
 	fmt.Println("You can now use 'hue-control list' to see your rooms.")
 }
 
-func createUser(bridgeIP string) (string, error) {
+// errLinkButtonNotPressed is returned by createUser while the bridge is waiting for its link button
+var errLinkButtonNotPressed = errors.New("link button not pressed")
+
+// waitForLink calls createUser every second until the link button has been
+// pressed or the timeout expires
+func waitForLink(bridgeIP string, timeout time.Duration) (string, string, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		apiKey, clientKey, err := createUser(bridgeIP)
+		if err == nil {
+			return apiKey, clientKey, nil
+		}
+		if !errors.Is(err, errLinkButtonNotPressed) {
+			return "", "", err
+		}
+		if time.Now().After(deadline) {
+			return "", "", fmt.Errorf("link button not pressed within %s", timeout)
+		}
+		time.Sleep(time.Second)
+	}
+}
+
+// createUser registers hue-control with the bridge and returns the API key
+// and the client key used for entertainment streaming
+func createUser(bridgeIP string) (string, string, error) {
 	client := getHTTPClient()
 	url := fmt.Sprintf("https://%s/api", bridgeIP)
 
-	body := map[string]string{
-		"devicetype": "hue-control#cli",
+	body := map[string]interface{}{
+		"devicetype":        "hue-control#cli",
+		"generateclientkey": true,
 	}
 	jsonBody, _ := json.Marshal(body)
 
 	resp, err := client.Post(url, "application/json", bytes.NewBuffer(jsonBody))
 	if err != nil {
-		return "", fmt.Errorf("failed to connect to bridge: %v", err)
+		return "", "", fmt.Errorf("failed to connect to bridge: %v", err)
 	}
 	defer resp.Body.Close()
 
 	respBody, _ := io.ReadAll(resp.Body)
 
-	var result []map[string]interface{}
+	var result []bridgeResult
 	if err := json.Unmarshal(respBody, &result); err != nil {
-		return "", fmt.Errorf("invalid response from bridge")
+		return "", "", fmt.Errorf("invalid response from bridge")
 	}
 
 	if len(result) == 0 {
-		return "", fmt.Errorf("empty response from bridge")
+		return "", "", fmt.Errorf("empty response from bridge")
 	}
 
-	if errInfo, ok := result[0]["error"]; ok {
-		errMap := errInfo.(map[string]interface{})
-		return "", fmt.Errorf("%v", errMap["description"])
+	if result[0].Error != nil {
+		// Error type 101: link button not pressed
+		if result[0].Error.Type == 101 {
+			return "", "", errLinkButtonNotPressed
+		}
+		return "", "", fmt.Errorf("%s", result[0].Error.Description)
 	}
 
-	if successInfo, ok := result[0]["success"]; ok {
-		successMap := successInfo.(map[string]interface{})
-		if username, ok := successMap["username"]; ok {
-			return username.(string), nil
-		}
+	if username, ok := result[0].Success["username"].(string); ok {
+		clientKey, _ := result[0].Success["clientkey"].(string)
+		return username, clientKey, nil
 	}
 
-	return "", fmt.Errorf("unexpected response from bridge")
+	return "", "", fmt.Errorf("unexpected response from bridge")
 }
 
 func runList() {
@@ -283,34 +550,96 @@ func runList() {
 
 	fmt.Println("Available Rooms/Groups:")
 	fmt.Println("------------------------")
-	for id, group := range groups {
+	for _, id := range sortedIDs(groups) {
+		group := groups[id]
 		status := "off"
 		if group.Action.On {
-			brightness := int(float64(group.Action.Bri) / 254.0 * 100)
-			status = fmt.Sprintf("on (%d%%)", brightness)
+			status = fmt.Sprintf("on (%d%%)", briToPercent(group.Action.Bri))
 		}
 		fmt.Printf("  [%s] %s (%s) - %d lights - %s\n", id, group.Name, group.Type, len(group.Lights), status)
 	}
 }
 
 func getGroups(config *Config) (map[string]Group, error) {
-	client := getHTTPClient()
-	url := fmt.Sprintf("https://%s/api/%s/groups", config.BridgeIP, config.APIKey)
+	var groups map[string]Group
+	if err := bridgeGet(config, "/groups", &groups); err != nil {
+		return nil, err
+	}
 
-	resp, err := client.Get(url)
-	if err != nil {
-		return nil, fmt.Errorf("failed to connect to bridge: %v", err)
+	return groups, nil
+}
+
+// findGroup looks up a group by name (case-insensitive) or by ID
+func findGroup(groups map[string]Group, nameOrID string) (string, bool) {
+	for _, id := range sortedIDs(groups) {
+		if strings.EqualFold(groups[id].Name, nameOrID) {
+			return id, true
+		}
 	}
-	defer resp.Body.Close()
+	if _, ok := groups[nameOrID]; ok {
+		return nameOrID, true
+	}
+	return "", false
+}
 
-	body, _ := io.ReadAll(resp.Body)
+func getLights(config *Config) (map[string]Light, error) {
+	var lights map[string]Light
+	if err := bridgeGet(config, "/lights", &lights); err != nil {
+		return nil, err
+	}
 
-	var groups map[string]Group
-	if err := json.Unmarshal(body, &groups); err != nil {
-		return nil, fmt.Errorf("invalid response: %v", err)
+	return lights, nil
+}
+
+// findLight looks up a light by name (case-insensitive) or by ID
+func findLight(lights map[string]Light, nameOrID string) (string, bool) {
+	for _, id := range sortedIDs(lights) {
+		if strings.EqualFold(lights[id].Name, nameOrID) {
+			return id, true
+		}
+	}
+	if _, ok := lights[nameOrID]; ok {
+		return nameOrID, true
 	}
+	return "", false
+}
 
-	return groups, nil
+// flagPassed reports whether a flag was given on the command line
+func flagPassed(fs *flag.FlagSet, name string) bool {
+	passed := false
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			passed = true
+		}
+	})
+	return passed
+}
+
+// parseInterspersed parses flags that may appear before, between or after
+// positional arguments and returns the positional arguments
+func parseInterspersed(fs *flag.FlagSet, args []string) []string {
+	var positional []string
+	for {
+		fs.Parse(args)
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+// percentToBri converts a brightness percentage to the bridge's 1-254 range
+// using the configured brightness curve
+func percentToBri(percent int) int {
+	return activeBrightnessCurve().bri(percent)
+}
+
+// briToPercent converts the bridge's 1-254 brightness to a percentage
+// using the configured brightness curve
+func briToPercent(bri int) int {
+	return activeBrightnessCurve().percent(bri)
 }
 
 func runSet() {
@@ -320,6 +649,13 @@ func runSet() {
 	hueVal := setCmd.Int("hue", -1, "Hue value (0-65535)")
 	satVal := setCmd.Int("sat", -1, "Saturation value (0-254)")
 	colorName := setCmd.String("color", "", "Color preset name")
+	palette := setCmd.String("palette", "", "Palette name, or a comma-separated list of colors, spread across the lights")
+	gradient := setCmd.String("gradient", "", "Colors to blend across the lights, e.g. red..blue")
+	random := setCmd.Bool("random", false, "Assign palette or gradient colors to lights in random order")
+	lightName := setCmd.String("light", "", "Single light to control instead of a room")
+	points := setCmd.String("points", "", "Comma-separated colors for the segments of a gradient light")
+	gradientMode := setCmd.String("gradient-mode", "", "Gradient mode for --points: interpolated, mirrored, random, segmented")
+	verify := setCmd.Bool("verify", false, "Re-read the lights afterwards and report any that didn't change; exit status 2 if some didn't")
 	setCmd.Parse(os.Args[2:])
 
 	if *brightness < 0 || *brightness > 100 {
@@ -327,32 +663,81 @@ func runSet() {
 		os.Exit(1)
 	}
 
+	if *lightName != "" && *room != "all" {
+		fmt.Println("Error: Use either --room or --light, not both")
+		os.Exit(1)
+	}
+	if (*points != "" || *gradientMode != "") && *lightName == "" {
+		fmt.Println("Error: --points and --gradient-mode need --light <name>")
+		os.Exit(1)
+	}
+	if *lightName != "" && (*palette != "" || *gradient != "") {
+		fmt.Println("Error: --palette and --gradient apply to rooms; use --points for a single gradient light")
+		os.Exit(1)
+	}
+	if *points != "" {
+		if *colorName != "" || *hueVal >= 0 || *satVal >= 0 {
+			fmt.Println("Error: --points cannot be combined with --color, --hue or --sat")
+			os.Exit(1)
+		}
+		if *verify {
+			fmt.Println("Error: --verify is not supported with --points")
+			os.Exit(1)
+		}
+		runSetPoints(*lightName, *brightness, *points, *gradientMode)
+		return
+	}
+	if *gradientMode != "" {
+		fmt.Println("Error: --gradient-mode needs --points")
+		os.Exit(1)
+	}
+
+	if *palette != "" || *gradient != "" {
+		if *palette != "" && *gradient != "" {
+			fmt.Println("Error: Use either --palette or --gradient, not both")
+			os.Exit(1)
+		}
+		if *colorName != "" || *hueVal >= 0 || *satVal >= 0 {
+			fmt.Println("Error: --palette and --gradient cannot be combined with --color, --hue or --sat")
+			os.Exit(1)
+		}
+		runSetColors(*room, *brightness, *palette, *gradient, *random, *verify)
+		return
+	}
+
 	// Resolve color preset
-	var finalHue, finalSat int = -1, -1
+	color := map[string]interface{}{}
 	if *colorName != "" {
-		preset, ok := ColorPresets[strings.ToLower(*colorName)]
+		preset, ok := colorPresets()[strings.ToLower(*colorName)]
 		if !ok {
-			fmt.Printf("Error: Unknown color '%s'. Available: red, orange, yellow, green, cyan, blue, purple, pink, warm, cool, white\n", *colorName)
+			fmt.Printf("Error: Unknown color '%s'. Available: %s\n", *colorName, presetNames())
 			os.Exit(1)
 		}
-		finalHue = preset[0]
-		finalSat = preset[1]
+		color = preset.state()
+		// A preset's own brightness applies unless --brightness was given
+		if preset.Brightness != nil && !flagPassed(setCmd, "brightness") {
+			*brightness = *preset.Brightness
+		}
 	}
 
 	// Override with explicit hue/sat if provided
+	if *hueVal >= 0 || *satVal >= 0 {
+		delete(color, "xy")
+		delete(color, "ct")
+	}
 	if *hueVal >= 0 {
 		if *hueVal > 65535 {
 			fmt.Println("Error: Hue must be between 0 and 65535")
 			os.Exit(1)
 		}
-		finalHue = *hueVal
+		color["hue"] = *hueVal
 	}
 	if *satVal >= 0 {
 		if *satVal > 254 {
 			fmt.Println("Error: Saturation must be between 0 and 254")
 			os.Exit(1)
 		}
-		finalSat = *satVal
+		color["sat"] = *satVal
 	}
 
 	config, err := loadConfig()
@@ -362,32 +747,135 @@ func runSet() {
 	}
 
 	// Convert percentage to Hue brightness (1-254)
-	hueBrightness := int(float64(*brightness) / 100.0 * 254)
-	if hueBrightness < 1 && *brightness > 0 {
-		hueBrightness = 1
+	hueBrightness := percentToBri(*brightness)
+	if *verify {
+		recordSentStates()
 	}
 
-	if strings.ToLower(*room) == "all" {
-		err = setAllLights(config, true, hueBrightness, finalHue, finalSat)
+	target := *room
+	var notes []string
+	if *lightName != "" {
+		target = *lightName
+		notes, err = setSingleLight(config, *lightName, hueBrightness, color)
 	} else {
-		err = setRoomState(config, *room, hueBrightness, finalHue, finalSat)
+		notes, err = setRoomState(config, *room, hueBrightness, color)
 	}
 
 	if err != nil {
+		printNotes(notes)
 		fmt.Printf("Error: %v\n", err)
+		if *verify && sentStateCount() > 0 {
+			// Some lights were set; check those and report a partial failure
+			finishVerify(config, true)
+		}
 		os.Exit(1)
 	}
 
 	// Build output message
-	msg := fmt.Sprintf("Set %s to %d%% brightness", *room, *brightness)
-	if finalHue >= 0 || finalSat >= 0 {
-		if *colorName != "" {
-			msg += fmt.Sprintf(" with color '%s'", *colorName)
-		} else {
-			msg += fmt.Sprintf(" with hue=%d sat=%d", finalHue, finalSat)
+	msg := fmt.Sprintf("Set %s to %d%% brightness", target, *brightness)
+	if *colorName != "" && *hueVal < 0 && *satVal < 0 {
+		msg += fmt.Sprintf(" with color '%s'", *colorName)
+	} else if len(color) > 0 {
+		var parts []string
+		for _, key := range []string{"hue", "sat"} {
+			if v, ok := color[key]; ok {
+				parts = append(parts, fmt.Sprintf("%s=%v", key, v))
+			}
 		}
+		msg += " with " + strings.Join(parts, " ")
 	}
 	fmt.Println(msg)
+	printNotes(notes)
+	if *verify {
+		finishVerify(config, false)
+	}
+}
+
+// printNotes lists the lights whose state was adapted to their capabilities
+func printNotes(notes []string) {
+	if len(notes) > 0 {
+		fmt.Println("Adapted for lights that cannot show it:")
+	}
+	for _, note := range notes {
+		fmt.Printf("  %s\n", note)
+	}
+}
+
+// runSetPoints handles 'set --light <name> --points <colors>'
+func runSetPoints(lightName string, brightness int, points, mode string) {
+	colors, err := parseColors(strings.Split(points, ","))
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	config, err := loadConfig()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	lights, err := getLights(config)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	lightID, ok := findLight(lights, lightName)
+	if !ok {
+		fmt.Printf("Error: light '%s' not found. Use 'hue-control status' to see available lights\n", lightName)
+		os.Exit(1)
+	}
+
+	if err := setLightPoints(config, lightID, colors, brightness, mode); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Set %s to %d%% brightness with %d gradient points\n", lights[lightID].Name, brightness, len(colors))
+}
+
+// runSetColors handles 'set --palette' and 'set --gradient'
+func runSetColors(room string, brightness int, palette, gradient string, random, verify bool) {
+	var colors []rgbColor
+	var err error
+	if gradient != "" {
+		colors, err = resolveGradient(gradient)
+	} else {
+		colors, err = resolvePalette(palette)
+	}
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	config, err := loadConfig()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if verify {
+		recordSentStates()
+	}
+	count, plain, err := setRoomColors(config, room, percentToBri(brightness), colors, gradient != "", random)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		if verify && count > 0 {
+			finishVerify(config, true)
+		}
+		os.Exit(1)
+	}
+
+	what := fmt.Sprintf("palette '%s'", palette)
+	if gradient != "" {
+		what = fmt.Sprintf("gradient %s", gradient)
+	}
+	fmt.Printf("Set %s to %d%% brightness with %s across %d lights\n", room, brightness, what, count)
+	if len(plain) > 0 {
+		fmt.Printf("Brightness only (no color support): %s\n", strings.Join(plain, ", "))
+	}
+	if verify {
+		finishVerify(config, false)
+	}
 }
 
 func runOn() {
@@ -397,7 +885,7 @@ func runOn() {
 		os.Exit(1)
 	}
 
-	if err := setAllLights(config, true, 254, -1, -1); err != nil {
+	if err := setAllLights(config, true, 254, nil); err != nil {
 		fmt.Printf("Error: %v\n", err)
 		os.Exit(1)
 	}
@@ -412,7 +900,7 @@ func runOff() {
 		os.Exit(1)
 	}
 
-	if err := setAllLights(config, false, 0, -1, -1); err != nil {
+	if err := setAllLights(config, false, 0, nil); err != nil {
 		fmt.Printf("Error: %v\n", err)
 		os.Exit(1)
 	}
@@ -420,97 +908,116 @@ func runOff() {
 	fmt.Println("All lights turned off")
 }
 
-func setAllLights(config *Config, on bool, brightness int, hue int, sat int) error {
+func setAllLights(config *Config, on bool, brightness int, color map[string]interface{}) error {
 	groups, err := getGroups(config)
 	if err != nil {
 		return err
 	}
 
-	// Find group 0 (all lights) or iterate through all groups
-	client := getHTTPClient()
-
-	// Try to use the special "0" group which represents all lights
-	url := fmt.Sprintf("https://%s/api/%s/groups/0/action", config.BridgeIP, config.APIKey)
-
 	state := map[string]interface{}{
 		"on": on,
 	}
 	if on && brightness > 0 {
 		state["bri"] = brightness
 	}
-	if hue >= 0 {
-		state["hue"] = hue
-	}
-	if sat >= 0 {
-		state["sat"] = sat
+	for key, value := range color {
+		state[key] = value
 	}
 
-	jsonBody, _ := json.Marshal(state)
-	req, _ := http.NewRequest("PUT", url, bytes.NewBuffer(jsonBody))
-	req.Header.Set("Content-Type", "application/json")
+	// Try to use the special "0" group which represents all lights
+	_, err = bridgeWrite(config, "PUT", "/groups/0/action", state)
+	var denied *PolicyError
+	if err == nil || errors.As(err, &denied) {
+		return err
+	}
 
-	resp, err := client.Do(req)
-	if err != nil {
-		// Fall back to setting each group individually
-		for id := range groups {
-			url := fmt.Sprintf("https://%s/api/%s/groups/%s/action", config.BridgeIP, config.APIKey, id)
-			req, _ := http.NewRequest("PUT", url, bytes.NewBuffer(jsonBody))
-			req.Header.Set("Content-Type", "application/json")
-			resp, err := client.Do(req)
-			if err != nil {
-				continue
-			}
-			resp.Body.Close()
+	// Fall back to setting each group individually
+	failed := []string{"all lights: " + err.Error()}
+	for _, id := range sortedIDs(groups) {
+		if _, err := bridgeWrite(config, "PUT", "/groups/"+id+"/action", state); err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %v", groups[id].Name, err))
 		}
-		return nil
 	}
-	defer resp.Body.Close()
-
+	if len(failed) > 1 {
+		return fmt.Errorf("failed to set all lights: %s", strings.Join(failed, "; "))
+	}
 	return nil
 }
 
-func setRoomState(config *Config, roomName string, brightness int, hue int, sat int) error {
-	groups, err := getGroups(config)
+// setRoomState sets every light in a room, or all lights for "all", to the
+// brightness and color, translated per light to what it supports. It
+// returns a note for each light whose state was translated.
+func setRoomState(config *Config, roomName string, brightness int, color map[string]interface{}) ([]string, error) {
+	lights, err := getLights(config)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	// Find the group by name
-	var groupID string
-	for id, group := range groups {
-		if strings.EqualFold(group.Name, roomName) {
-			groupID = id
-			break
+	lightIDs := sortedIDs(lights)
+	if strings.ToLower(roomName) != "all" {
+		groups, err := getGroups(config)
+		if err != nil {
+			return nil, err
 		}
-	}
 
-	if groupID == "" {
-		return fmt.Errorf("room '%s' not found. Use 'hue-control list' to see available rooms", roomName)
+		// Find the group by name
+		groupID, ok := findGroup(groups, roomName)
+		if !ok {
+			return nil, fmt.Errorf("room '%s' not found. Use 'hue-control list' to see available rooms", roomName)
+		}
+		lightIDs = groups[groupID].Lights
 	}
 
-	client := getHTTPClient()
-	url := fmt.Sprintf("https://%s/api/%s/groups/%s/action", config.BridgeIP, config.APIKey, groupID)
+	return setLightsState(config, lights, lightIDs, roomStateRequest(brightness, color))
+}
 
+// roomStateRequest builds the state 'set' asks for before per-light translation
+func roomStateRequest(brightness int, color map[string]interface{}) map[string]interface{} {
 	state := map[string]interface{}{
 		"on":  true,
 		"bri": brightness,
 	}
-	if hue >= 0 {
-		state["hue"] = hue
+	for key, value := range color {
+		state[key] = value
 	}
-	if sat >= 0 {
-		state["sat"] = sat
+	return state
+}
+
+// setRoomPower turns every light in a room on or off without changing
+// brightness or color
+func setRoomPower(config *Config, roomName string, on bool) error {
+	groups, err := getGroups(config)
+	if err != nil {
+		return err
 	}
 
-	jsonBody, _ := json.Marshal(state)
-	req, _ := http.NewRequest("PUT", url, bytes.NewBuffer(jsonBody))
-	req.Header.Set("Content-Type", "application/json")
+	groupID, ok := findGroup(groups, roomName)
+	if !ok {
+		return fmt.Errorf("room '%s' not found. Use 'hue-control list' to see available rooms", roomName)
+	}
 
-	resp, err := client.Do(req)
+	_, err = bridgeWrite(config, "PUT", "/groups/"+groupID+"/action", map[string]interface{}{"on": on})
+	return err
+}
+
+// setSingleLight sets brightness and optionally a color of one light,
+// translated to what it supports
+func setSingleLight(config *Config, lightName string, brightness int, color map[string]interface{}) ([]string, error) {
+	lights, err := getLights(config)
 	if err != nil {
-		return fmt.Errorf("failed to connect to bridge: %v", err)
+		return nil, err
 	}
-	defer resp.Body.Close()
+	lightID, ok := findLight(lights, lightName)
+	if !ok {
+		return nil, fmt.Errorf("light '%s' not found. Use 'hue-control status' to see available lights", lightName)
+	}
+	return setLightsState(config, lights, []string{lightID}, roomStateRequest(brightness, color))
+}
 
-	return nil
+// setLightState sends a state change to a single light
+func setLightState(config *Config, lightID string, state map[string]interface{}) error {
+	recordSentState(lightID, state)
+	_, err := bridgeWrite(config, "PUT", "/lights/"+lightID+"/state", state)
+	recordSendResult(lightID, err)
+	return err
 }
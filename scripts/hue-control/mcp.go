@@ -0,0 +1,486 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// The Model Context Protocol (MCP) lets AI agents call typed tools instead of
+// composing shell commands. 'hue-control mcp' speaks it over stdio:
+// newline-delimited JSON-RPC 2.0 messages on stdin and stdout.
+
+// mcpProtocolVersions are the protocol versions we speak, newest first
+var mcpProtocolVersions = []string{"2025-06-18", "2025-03-26", "2024-11-05"}
+
+type mcpRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+type mcpResponse struct {
+	JSONRPC string      `json:"jsonrpc"`
+	ID      interface{} `json:"id"`
+	Result  interface{} `json:"result,omitempty"`
+	Error   *mcpError   `json:"error,omitempty"`
+}
+
+type mcpError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// JSON-RPC error codes
+const (
+	mcpParseError     = -32700
+	mcpInvalidRequest = -32600
+	mcpMethodNotFound = -32601
+	mcpInvalidParams  = -32602
+)
+
+// mcpTool is one tool offered to the agent. run returns a JSON object that
+// becomes the tool's structured result; an error becomes a tool error.
+type mcpTool struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description"`
+	InputSchema map[string]interface{} `json:"inputSchema"`
+
+	run func(config *Config, args json.RawMessage) (interface{}, error)
+}
+
+// mcpSchema builds an object schema from property schemas
+func mcpSchema(required []string, properties map[string]interface{}) map[string]interface{} {
+	schema := map[string]interface{}{
+		"type":                 "object",
+		"properties":           properties,
+		"additionalProperties": false,
+	}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+func mcpString(description string) map[string]interface{} {
+	return map[string]interface{}{"type": "string", "description": description}
+}
+
+func mcpPercent(description string) map[string]interface{} {
+	return map[string]interface{}{"type": "integer", "minimum": 0, "maximum": 100, "description": description}
+}
+
+// mcpTools returns the tools offered to agents
+func mcpTools() []mcpTool {
+	return []mcpTool{
+		{
+			Name:        "list_rooms",
+			Description: "List the rooms and zones on the Hue Bridge with their lights and whether they are on.",
+			InputSchema: mcpSchema(nil, map[string]interface{}{}),
+			run:         mcpListRooms,
+		},
+		{
+			Name:        "get_state",
+			Description: "Get the actual state of every light (on, brightness, nearest color name, reachable), grouped by room.",
+			InputSchema: mcpSchema(nil, map[string]interface{}{
+				"room": mcpString("Only return this room (name or ID)"),
+			}),
+			run: mcpGetState,
+		},
+		{
+			Name:        "set_room",
+			Description: "Turn a room on or off, or set its brightness and color. Colors that a light can't show are translated to the nearest white or dropped.",
+			InputSchema: mcpSchema([]string{"room"}, map[string]interface{}{
+				"room":       mcpString(`Room or zone name, or "all" for every light`),
+				"on":         map[string]interface{}{"type": "boolean", "description": "false turns the room off; brightness and color are ignored then"},
+				"brightness": mcpPercent("Brightness percentage (default: the color preset's, or 100)"),
+				"color":      mcpString("Color preset name (" + presetNames() + ") or hex color like #ff8800"),
+			}),
+			run: mcpSetRoom,
+		},
+		{
+			Name:        "recall_scene",
+			Description: "Activate a scene stored on the Bridge for a room.",
+			InputSchema: mcpSchema([]string{"room", "scene"}, map[string]interface{}{
+				"room":  mcpString("Room or zone name"),
+				"scene": mcpString("Scene name (case-insensitive) or ID"),
+			}),
+			run: mcpRecallScene,
+		},
+		{
+			Name:        "weather_mode",
+			Description: "Set the lights to a color that matches the current weather, using the weather-lights tool.",
+			InputSchema: mcpSchema(nil, map[string]interface{}{
+				"location":   mcpString("City or place for the weather (default: auto-detect)"),
+				"room":       mcpString(`Room to change (default: "all")`),
+				"brightness": mcpPercent("Brightness percentage (default: 80)"),
+				"dry_run":    map[string]interface{}{"type": "boolean", "description": "Only report the weather and color, don't change the lights"},
+			}),
+			run: mcpWeatherMode,
+		},
+	}
+
+}
+
+func runMCP() {
+	config, err := loadConfig()
+	if err != nil {
+		// Report a usable error to the agent on every tool call rather than
+		// failing the handshake
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		config = nil
+	}
+
+	in := bufio.NewScanner(os.Stdin)
+	in.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	out := json.NewEncoder(os.Stdout)
+
+	for in.Scan() {
+		line := bytes.TrimSpace(in.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		if resp := handleMCPMessage(config, err, line); resp != nil {
+			if encodeErr := out.Encode(resp); encodeErr != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", encodeErr)
+				os.Exit(1)
+			}
+		}
+	}
+	if err := in.Err(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// handleMCPMessage answers one JSON-RPC message; notifications get no
+// answer. configErr is the error from loading the config, if any.
+func handleMCPMessage(config *Config, configErr error, line []byte) *mcpResponse {
+	var req mcpRequest
+	if err := json.Unmarshal(line, &req); err != nil {
+		return &mcpResponse{JSONRPC: "2.0", Error: &mcpError{mcpParseError, "parse error: " + err.Error()}}
+	}
+	if len(req.ID) == 0 {
+		// Notifications such as notifications/initialized need no reply
+		return nil
+	}
+	resp := &mcpResponse{JSONRPC: "2.0", ID: req.ID}
+	if req.JSONRPC != "2.0" || req.Method == "" {
+		resp.Error = &mcpError{mcpInvalidRequest, "invalid JSON-RPC 2.0 request"}
+		return resp
+	}
+
+	switch req.Method {
+	case "initialize":
+		var params struct {
+			ProtocolVersion string `json:"protocolVersion"`
+		}
+		json.Unmarshal(req.Params, &params)
+		version := mcpProtocolVersions[0]
+		for _, v := range mcpProtocolVersions {
+			if v == params.ProtocolVersion {
+				version = v
+			}
+		}
+		resp.Result = map[string]interface{}{
+			"protocolVersion": version,
+			"capabilities":    map[string]interface{}{"tools": map[string]interface{}{}},
+			"serverInfo":      map[string]interface{}{"name": "hue-control", "version": "1.0.0"},
+			"instructions":    "Controls Philips Hue lights. Call list_rooms first to learn the room names.",
+		}
+
+	case "ping":
+		resp.Result = map[string]interface{}{}
+
+	case "tools/list":
+		resp.Result = map[string]interface{}{"tools": mcpTools()}
+
+	case "tools/call":
+		var params struct {
+			Name      string          `json:"name"`
+			Arguments json.RawMessage `json:"arguments"`
+		}
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			resp.Error = &mcpError{mcpInvalidParams, "invalid params: " + err.Error()}
+			return resp
+		}
+		var tool *mcpTool
+		tools := mcpTools()
+		for i := range tools {
+			if tools[i].Name == params.Name {
+				tool = &tools[i]
+			}
+		}
+		if tool == nil {
+			resp.Error = &mcpError{mcpInvalidParams, fmt.Sprintf("unknown tool '%s'", params.Name)}
+			return resp
+		}
+		if len(params.Arguments) == 0 || string(params.Arguments) == "null" {
+			params.Arguments = json.RawMessage("{}")
+		}
+
+		var result interface{}
+		err := configErr
+		if err == nil {
+			result, err = tool.run(beginPolicyCommand(config), params.Arguments)
+		}
+		resp.Result = mcpToolResult(result, err)
+
+	default:
+		resp.Error = &mcpError{mcpMethodNotFound, fmt.Sprintf("method '%s' not found", req.Method)}
+	}
+	return resp
+}
+
+// mcpToolResult wraps a tool's result, or its error as a tool error the
+// agent can read and act on. Policy denials also carry the rule that
+// denied the change.
+func mcpToolResult(result interface{}, err error) map[string]interface{} {
+	if err != nil {
+		toolError := map[string]interface{}{
+			"content": []map[string]interface{}{{"type": "text", "text": "Error: " + err.Error()}},
+			"isError": true,
+		}
+		var denied *PolicyError
+		if errors.As(err, &denied) {
+			toolError["structuredContent"] = map[string]interface{}{"denied": denied}
+		}
+		return toolError
+	}
+	text, _ := json.MarshalIndent(result, "", "  ")
+	return map[string]interface{}{
+		"content":           []map[string]interface{}{{"type": "text", "text": string(text)}},
+		"structuredContent": result,
+		"isError":           false,
+	}
+}
+
+// decodeMCPArgs decodes tool arguments, rejecting unknown fields so typos
+// don't silently do nothing
+func decodeMCPArgs(args json.RawMessage, v interface{}) error {
+	dec := json.NewDecoder(bytes.NewReader(args))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return fmt.Errorf("invalid arguments: %v", err)
+	}
+	return nil
+}
+
+// mcpRoom is one room in the list_rooms result
+type mcpRoom struct {
+	ID         string `json:"id"`
+	Name       string `json:"name"`
+	Type       string `json:"type"`
+	Lights     int    `json:"lights"`
+	AnyOn      bool   `json:"any_on"`
+	AllOn      bool   `json:"all_on"`
+	Brightness int    `json:"brightness"`
+}
+
+func mcpListRooms(config *Config, args json.RawMessage) (interface{}, error) {
+	if err := decodeMCPArgs(args, &struct{}{}); err != nil {
+		return nil, err
+	}
+	groups, err := getGroups(config)
+	if err != nil {
+		return nil, err
+	}
+	rooms := []mcpRoom{}
+	for _, id := range sortedIDs(groups) {
+		group := groups[id]
+		if group.Type != "Room" && group.Type != "Zone" {
+			continue
+		}
+		rooms = append(rooms, mcpRoom{
+			ID:         id,
+			Name:       group.Name,
+			Type:       group.Type,
+			Lights:     len(group.Lights),
+			AnyOn:      group.State.AnyOn,
+			AllOn:      group.State.AllOn,
+			Brightness: briToPercent(group.Action.Bri),
+		})
+	}
+	return map[string]interface{}{"rooms": rooms}, nil
+}
+
+func mcpGetState(config *Config, args json.RawMessage) (interface{}, error) {
+	var a struct {
+		Room string `json:"room"`
+	}
+	if err := decodeMCPArgs(args, &a); err != nil {
+		return nil, err
+	}
+	groups, err := getGroups(config)
+	if err != nil {
+		return nil, err
+	}
+	lights, err := getLights(config)
+	if err != nil {
+		return nil, err
+	}
+
+	if a.Room == "" {
+		return map[string]interface{}{"rooms": buildRoomStatuses(groups, lights)}, nil
+	}
+	groupID, ok := findGroup(groups, a.Room)
+	if !ok {
+		return nil, fmt.Errorf("room '%s' not found. Call list_rooms to see available rooms", a.Room)
+	}
+	return map[string]interface{}{"rooms": []RoomStatus{newRoomStatus(groupID, groups[groupID], lights)}}, nil
+}
+
+func mcpSetRoom(config *Config, args json.RawMessage) (interface{}, error) {
+	var a struct {
+		Room       string `json:"room"`
+		On         *bool  `json:"on"`
+		Brightness *int   `json:"brightness"`
+		Color      string `json:"color"`
+	}
+	if err := decodeMCPArgs(args, &a); err != nil {
+		return nil, err
+	}
+	if a.Room == "" {
+		return nil, fmt.Errorf("room is required")
+	}
+
+	if a.On != nil && !*a.On {
+		var err error
+		if strings.EqualFold(a.Room, "all") {
+			err = setAllLights(config, false, 0, nil)
+		} else {
+			err = setRoomPower(config, a.Room, false)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"room": a.Room, "on": false}, nil
+	}
+
+	brightness := 100
+	var color map[string]interface{}
+	if a.Color != "" {
+		var err error
+		if color, err = colorState(a.Color); err != nil {
+			return nil, err
+		}
+		if preset, ok := colorPresets()[strings.ToLower(a.Color)]; ok && preset.Brightness != nil {
+			brightness = *preset.Brightness
+		}
+	}
+	if a.Brightness != nil {
+		if *a.Brightness < 0 || *a.Brightness > 100 {
+			return nil, fmt.Errorf("brightness must be between 0 and 100")
+		}
+		brightness = *a.Brightness
+	}
+
+	notes, err := setRoomState(config, a.Room, percentToBri(brightness), color)
+	if err != nil {
+		return nil, err
+	}
+	result := map[string]interface{}{"room": a.Room, "on": true, "brightness": brightness}
+	if a.Color != "" {
+		result["color"] = a.Color
+	}
+	if len(notes) > 0 {
+		result["translated"] = notes
+	}
+	return result, nil
+}
+
+func mcpRecallScene(config *Config, args json.RawMessage) (interface{}, error) {
+	var a struct {
+		Room  string `json:"room"`
+		Scene string `json:"scene"`
+	}
+	if err := decodeMCPArgs(args, &a); err != nil {
+		return nil, err
+	}
+	if a.Room == "" || a.Scene == "" {
+		return nil, fmt.Errorf("room and scene are required")
+	}
+	if err := recallScene(config, a.Room, a.Scene); err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"room": a.Room, "scene": a.Scene}, nil
+}
+
+func mcpWeatherMode(config *Config, args json.RawMessage) (interface{}, error) {
+	var a struct {
+		Location   string `json:"location"`
+		Room       string `json:"room"`
+		Brightness *int   `json:"brightness"`
+		DryRun     bool   `json:"dry_run"`
+	}
+	if err := decodeMCPArgs(args, &a); err != nil {
+		return nil, err
+	}
+
+	path, err := findWeatherLights()
+	if err != nil {
+		return nil, err
+	}
+	cmdArgs := []string{"--json"}
+	if a.Location != "" {
+		cmdArgs = append(cmdArgs, "--location", a.Location)
+	}
+	if a.Room != "" {
+		cmdArgs = append(cmdArgs, "--room", a.Room)
+	}
+	if a.Brightness != nil {
+		if *a.Brightness < 0 || *a.Brightness > 100 {
+			return nil, fmt.Errorf("brightness must be between 0 and 100")
+		}
+		cmdArgs = append(cmdArgs, "--brightness", fmt.Sprint(*a.Brightness))
+	}
+	if a.DryRun {
+		cmdArgs = append(cmdArgs, "--dry-run")
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(path, cmdArgs...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		msg := strings.TrimSpace(stdout.String() + "\n" + stderr.String())
+		msg = strings.TrimSpace(strings.TrimPrefix(msg, "Error:"))
+		if msg == "" {
+			msg = err.Error()
+		}
+		return nil, fmt.Errorf("weather-lights failed: %s", msg)
+	}
+
+	var result map[string]interface{}
+	if err := json.Unmarshal(stdout.Bytes(), &result); err != nil {
+		return nil, fmt.Errorf("failed to parse weather-lights output: %v", err)
+	}
+	return result, nil
+}
+
+// findWeatherLights locates the weather-lights binary next to this one, in
+// the repo layout or on the PATH
+func findWeatherLights() (string, error) {
+	if execPath, err := os.Executable(); err == nil {
+		dir := filepath.Dir(execPath)
+		for _, p := range []string{
+			filepath.Join(dir, "..", "weather-lights", "weather-lights"),
+			filepath.Join(dir, "weather-lights"),
+		} {
+			if _, err := os.Stat(p); err == nil {
+				return p, nil
+			}
+		}
+	}
+	if path, err := exec.LookPath("weather-lights"); err == nil {
+		return path, nil
+	}
+	return "", fmt.Errorf("weather-lights binary not found. Build it first with ./scripts/build.sh")
+}
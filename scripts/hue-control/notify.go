@@ -0,0 +1,306 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// notification is a flash of a room or light that is undone afterwards
+type notification struct {
+	Room       string
+	Light      string
+	Color      map[string]interface{} // state fields for the color; empty keeps each light's color
+	Brightness int                    // percent
+	Count      int
+	OnTime     time.Duration
+	OffTime    time.Duration
+	Alert      bool // use the bridge's 15 second lselect alert instead of flashing
+}
+
+// notifyLockStale is how old a lock file may get before it is assumed to be
+// left over from a crashed notification
+const notifyLockStale = 2 * time.Minute
+
+// notifyMu queues notifications within one process (e.g. the webhook
+// receiver); the lock file queues them across processes
+var notifyMu sync.Mutex
+
+// colorState returns the state fields for a preset name or hex color
+func colorState(spec string) (map[string]interface{}, error) {
+	if preset, ok := colorPresets()[strings.ToLower(strings.TrimSpace(spec))]; ok {
+		return preset.state(), nil
+	}
+	c, err := parseHexColor(spec)
+	if err != nil {
+		return nil, fmt.Errorf("unknown color '%s'. Use a hex color or one of: %s", spec, presetNames())
+	}
+	return map[string]interface{}{"xy": c.toXY()}, nil
+}
+
+func runNotify() {
+	notifyCmd := flag.NewFlagSet("notify", flag.ExitOnError)
+	room := notifyCmd.String("room", "", "Room to flash")
+	light := notifyCmd.String("light", "", "Single light to flash instead of a room")
+	color := notifyCmd.String("color", "", "Color preset or hex color to flash (default: keep each light's color)")
+	brightness := notifyCmd.Int("brightness", 100, "Brightness percentage of the flash")
+	count := notifyCmd.Int("count", 3, "Number of flashes")
+	onTime := notifyCmd.Duration("on", 500*time.Millisecond, "How long each flash stays on")
+	offTime := notifyCmd.Duration("off", 500*time.Millisecond, "Pause between flashes")
+	alert := notifyCmd.Bool("alert", false, "Use the bridge's built-in 15 second alert instead of flashing")
+	wait := notifyCmd.Duration("wait", time.Minute, "How long to wait for an earlier notification to finish")
+	notifyCmd.Parse(os.Args[2:])
+
+	if (*room == "") == (*light == "") {
+		fmt.Println("Error: Give either --room or --light")
+		os.Exit(1)
+	}
+	if *brightness < 1 || *brightness > 100 {
+		fmt.Println("Error: Brightness must be between 1 and 100")
+		os.Exit(1)
+	}
+	if *count < 1 || *count > 20 {
+		fmt.Println("Error: Count must be between 1 and 20")
+		os.Exit(1)
+	}
+
+	n := notification{
+		Room:       *room,
+		Light:      *light,
+		Brightness: *brightness,
+		Count:      *count,
+		OnTime:     *onTime,
+		OffTime:    *offTime,
+		Alert:      *alert,
+	}
+	if *color != "" {
+		var err error
+		if n.Color, err = colorState(*color); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	config, err := loadConfig()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Ctrl-C stops the flashing early but still restores the lights
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+
+	target, err := runNotification(config, n, *wait, stop)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Notified %s and restored its lights\n", target)
+}
+
+// runNotification waits for any earlier notification to finish, captures
+// the state of the lights, flashes them and restores the captured state.
+// A value on stop ends the flashing early; the lights are still restored.
+// It returns the name of the room or light.
+func runNotification(config *Config, n notification, wait time.Duration, stop <-chan os.Signal) (string, error) {
+	notifyMu.Lock()
+	defer notifyMu.Unlock()
+
+	release, err := acquireNotifyLock(wait)
+	if err != nil {
+		return "", err
+	}
+	defer release()
+
+	lights, err := getLights(config)
+	if err != nil {
+		return "", err
+	}
+	target, lightIDs, err := notificationLights(config, lights, n)
+	if err != nil {
+		return "", err
+	}
+
+	// Unreachable lights can neither flash nor be restored
+	var ids []string
+	for _, id := range lightIDs {
+		if lights[id].State.Reachable {
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 {
+		return "", fmt.Errorf("no reachable lights in '%s'", target)
+	}
+
+	flash := map[string]interface{}{"on": true, "bri": percentToBri(n.Brightness), "transitiontime": 0}
+	for key, value := range n.Color {
+		flash[key] = value
+	}
+	dark := map[string]interface{}{"on": false, "transitiontime": 0}
+
+	// changed holds the lights that must be restored: those that were on
+	// once anything reached them, and those that were off while they are lit
+	changed := map[string]bool{}
+	send := func(lightIDs []string, state map[string]interface{}) error {
+		_, set, err := sendLightsState(config, lights, lightIDs, state)
+		for _, id := range set {
+			changed[id] = true
+		}
+		return err
+	}
+	var wasOn []string
+	for _, id := range ids {
+		if lights[id].State.On {
+			wasOn = append(wasOn, id)
+		}
+	}
+	// Lights that were off get their own state back whenever they go dark,
+	// so they never have to be turned on again just to restore it
+	darken := func() error {
+		if err := send(wasOn, dark); err != nil {
+			return err
+		}
+		var lit []string
+		for _, id := range ids {
+			if changed[id] && !lights[id].State.On {
+				lit = append(lit, id)
+			}
+		}
+		restored, err := restoreLights(config, lights, lit, false)
+		for _, id := range restored {
+			delete(changed, id)
+		}
+		return err
+	}
+
+	sleep := func(d time.Duration) bool {
+		select {
+		case <-stop:
+			return false
+		case <-time.After(d):
+			return true
+		}
+	}
+
+	var flashErr error
+	if n.Alert {
+		if flashErr = send(ids, flash); flashErr == nil {
+			// On/off plugs have no alert
+			var dimmable []string
+			for _, id := range ids {
+				if lights[id].dimmable() {
+					dimmable = append(dimmable, id)
+				}
+			}
+			flashErr = send(dimmable, map[string]interface{}{"alert": "lselect"})
+			sleep(15 * time.Second)
+		}
+	} else {
+		for i := 0; i < n.Count && flashErr == nil; i++ {
+			if flashErr = send(ids, flash); flashErr != nil || !sleep(n.OnTime) {
+				break
+			}
+			if flashErr = darken(); flashErr != nil {
+				break
+			}
+			if i < n.Count-1 && !sleep(n.OffTime) {
+				break
+			}
+		}
+	}
+
+	var restore []string
+	for _, id := range ids {
+		if changed[id] {
+			restore = append(restore, id)
+		}
+	}
+	var restoreErr error
+	if len(restore) > 0 {
+		_, restoreErr = restoreLights(config, lights, restore, n.Alert)
+	}
+	if flashErr != nil {
+		return target, flashErr
+	}
+	return target, restoreErr
+}
+
+// notificationLights resolves the room or light of a notification
+func notificationLights(config *Config, lights map[string]Light, n notification) (string, []string, error) {
+	if n.Light != "" {
+		id, ok := findLight(lights, n.Light)
+		if !ok {
+			return "", nil, fmt.Errorf("light '%s' not found. Use 'hue-control status' to see available lights", n.Light)
+		}
+		return lights[id].Name, []string{id}, nil
+	}
+
+	groups, err := getGroups(config)
+	if err != nil {
+		return "", nil, err
+	}
+	groupID, ok := findGroup(groups, n.Room)
+	if !ok {
+		return "", nil, fmt.Errorf("room '%s' not found. Use 'hue-control list' to see available rooms", n.Room)
+	}
+	return groups[groupID].Name, groups[groupID].Lights, nil
+}
+
+// restoreLights puts each light back into its captured state, one request
+// per light. Lights that were off get their brightness and color back in
+// the request that turns them off, which the bridge only accepts from a
+// light that is on, so those lights must be lit when this is called.
+// cancelAlert also stops a running bridge alert. It returns the IDs of the
+// lights that were restored.
+func restoreLights(config *Config, captured map[string]Light, lightIDs []string, cancelAlert bool) ([]string, error) {
+	var restored, failed []string
+	for _, id := range lightIDs {
+		light := captured[id]
+		state := map[string]interface{}{"on": light.State.On}
+		if light.dimmable() {
+			state["bri"] = light.State.Bri
+			state["transitiontime"] = 0
+			if cancelAlert {
+				state["alert"] = "none"
+			}
+		}
+		switch light.State.ColorMode {
+		case "xy":
+			if len(light.State.XY) == 2 {
+				state["xy"] = light.State.XY
+			}
+		case "ct":
+			state["ct"] = light.State.CT
+		case "hs":
+			state["hue"] = light.State.Hue
+			state["sat"] = light.State.Sat
+		}
+
+		if err := setLightState(config, id, state); err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %v", light.Name, err))
+			continue
+		}
+		restored = append(restored, id)
+	}
+	if len(failed) > 0 {
+		return restored, fmt.Errorf("failed to restore %d lights: %s", len(failed), strings.Join(failed, "; "))
+	}
+	return restored, nil
+}
+
+// acquireNotifyLock waits until no other notification is running and
+// returns a function that releases the lock
+func acquireNotifyLock(wait time.Duration) (func(), error) {
+	release, err := acquireFileLock("notify.lock", wait, notifyLockStale)
+	if errors.Is(err, errLocked) {
+		return nil, fmt.Errorf("another notification is still running (%v)", err)
+	}
+	return release, err
+}
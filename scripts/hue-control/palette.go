@@ -0,0 +1,325 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// rgbColor is an sRGB color with channels from 0 to 1
+type rgbColor struct {
+	R, G, B float64
+}
+
+// oklabColor is a color in the OKLab perceptual color space, where equal
+// steps look like equal changes in color
+type oklabColor struct {
+	L, A, B float64
+}
+
+// builtinPalettes are available to 'set --palette' without any configuration
+var builtinPalettes = map[string][]string{
+	"sunset": {"#ff5e3a", "#ff9a2e", "#ff2d6f", "#9b2fae"},
+	"ocean":  {"#023e8a", "#0077b6", "#00b4d8", "#48cae4"},
+	"forest": {"#1b5e20", "#2e7d32", "#7cb342", "#c0ca33"},
+	"fire":   {"#ff0000", "#ff4500", "#ff8c00", "#ffc300"},
+	"aurora": {"#00ff87", "#00c9a7", "#4b0082", "#c724b1"},
+	"candy":  {"pink", "purple", "cyan", "yellow"},
+}
+
+// palettesFile is the optional user file with extra palettes, e.g.
+// {"palettes": {"party": ["red", "#00ff00", "blue"]}}
+type palettesFile struct {
+	Palettes map[string][]string `json:"palettes"`
+}
+
+func palettesPath() (string, error) {
+	return userFilePath("palettes.json")
+}
+
+// loadPalettes returns the built-in palettes merged with the user's
+// palettes file; a user palette replaces a built-in one of the same name
+func loadPalettes() (map[string][]string, error) {
+	palettes := map[string][]string{}
+	for name, colors := range builtinPalettes {
+		palettes[name] = colors
+	}
+
+	path, err := palettesPath()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return palettes, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var file palettesFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("invalid palettes file %s: %v", path, err)
+	}
+	for name, colors := range file.Palettes {
+		if len(colors) == 0 {
+			return nil, fmt.Errorf("palette '%s' in %s has no colors", name, path)
+		}
+		palettes[strings.ToLower(name)] = colors
+	}
+	return palettes, nil
+}
+
+// resolvePalette returns the colors of a named palette, or of an explicit
+// comma-separated list of colors
+func resolvePalette(spec string) ([]rgbColor, error) {
+	palettes, err := loadPalettes()
+	if err != nil {
+		return nil, err
+	}
+
+	names, ok := palettes[strings.ToLower(strings.TrimSpace(spec))]
+	if !ok {
+		if !strings.Contains(spec, ",") {
+			return nil, fmt.Errorf("unknown palette '%s'. Available: %s, or a list of colors like \"red,#00ff00,blue\"", spec, strings.Join(sortedIDs(palettes), ", "))
+		}
+		names = strings.Split(spec, ",")
+	}
+	return parseColors(names)
+}
+
+// resolveGradient returns the color stops of a gradient spec such as
+// "red..blue" or "red..#ffff00..blue"
+func resolveGradient(spec string) ([]rgbColor, error) {
+	stops := strings.Split(spec, "..")
+	if len(stops) < 2 {
+		return nil, fmt.Errorf("invalid gradient '%s', expected at least two colors like red..blue", spec)
+	}
+	return parseColors(stops)
+}
+
+func parseColors(specs []string) ([]rgbColor, error) {
+	var colors []rgbColor
+	for _, s := range specs {
+		if strings.TrimSpace(s) == "" {
+			continue
+		}
+		c, err := parseColor(s)
+		if err != nil {
+			return nil, err
+		}
+		colors = append(colors, c)
+	}
+	if len(colors) == 0 {
+		return nil, fmt.Errorf("no colors given")
+	}
+	return colors, nil
+}
+
+// parseColor accepts a hex color ("#ff8800" or "#f80") or a color preset name
+func parseColor(spec string) (rgbColor, error) {
+	s := strings.ToLower(strings.TrimSpace(spec))
+	if preset, ok := colorPresets()[s]; ok {
+		return preset.rgb(), nil
+	}
+	if c, err := parseHexColor(s); err == nil {
+		return c, nil
+	}
+	return rgbColor{}, fmt.Errorf("invalid color '%s', expected a preset name or hex like #ff8800", spec)
+}
+
+// parseHexColor parses "#rrggbb" or "#rgb", with or without the "#"
+func parseHexColor(s string) (rgbColor, error) {
+	hex := strings.TrimPrefix(strings.TrimSpace(s), "#")
+	if len(hex) == 3 {
+		hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+	}
+	if len(hex) == 6 {
+		if v, err := strconv.ParseUint(hex, 16, 32); err == nil {
+			return rgbColor{
+				R: float64(v>>16&0xff) / 255,
+				G: float64(v>>8&0xff) / 255,
+				B: float64(v&0xff) / 255,
+			}, nil
+		}
+	}
+	return rgbColor{}, fmt.Errorf("invalid hex color '%s', expected e.g. #ff8800", s)
+}
+
+// hueSatToRGB converts bridge hue (0-65535) and saturation (0-254) at full
+// value to sRGB; the inverse of rgbToHueSat
+func hueSatToRGB(hue, sat int) rgbColor {
+	h := float64(hue) / 65536 * 6
+	s := float64(sat) / 254
+	f := h - math.Floor(h)
+	p, q, t := 1-s, 1-s*f, 1-s*(1-f)
+
+	var r, g, b float64
+	switch int(h) % 6 {
+	case 0:
+		r, g, b = 1, t, p
+	case 1:
+		r, g, b = q, 1, p
+	case 2:
+		r, g, b = p, 1, t
+	case 3:
+		r, g, b = p, q, 1
+	case 4:
+		r, g, b = t, p, 1
+	default:
+		r, g, b = 1, p, q
+	}
+	return rgbColor{R: linearToSRGB(r), G: linearToSRGB(g), B: linearToSRGB(b)}
+}
+
+func srgbToLinear(c float64) float64 {
+	if c <= 0.04045 {
+		return c / 12.92
+	}
+	return math.Pow((c+0.055)/1.055, 2.4)
+}
+
+func linearToSRGB(c float64) float64 {
+	c = math.Max(0, math.Min(1, c))
+	if c <= 0.0031308 {
+		return c * 12.92
+	}
+	return 1.055*math.Pow(c, 1/2.4) - 0.055
+}
+
+// toOKLab converts to OKLab (Björn Ottosson's matrices)
+func (c rgbColor) toOKLab() oklabColor {
+	r, g, b := srgbToLinear(c.R), srgbToLinear(c.G), srgbToLinear(c.B)
+	l := math.Cbrt(0.4122214708*r + 0.5363325363*g + 0.0514459929*b)
+	m := math.Cbrt(0.2119034982*r + 0.6806995451*g + 0.1073969566*b)
+	s := math.Cbrt(0.0883024619*r + 0.2817188376*g + 0.6299787005*b)
+	return oklabColor{
+		L: 0.2104542553*l + 0.7936177850*m - 0.0040720468*s,
+		A: 1.9779984951*l - 2.4285922050*m + 0.4505937099*s,
+		B: 0.0259040371*l + 0.7827717662*m - 0.8086757660*s,
+	}
+}
+
+func (o oklabColor) toRGB() rgbColor {
+	l := math.Pow(o.L+0.3963377774*o.A+0.2158037573*o.B, 3)
+	m := math.Pow(o.L-0.1055613458*o.A-0.0638541728*o.B, 3)
+	s := math.Pow(o.L-0.0894841775*o.A-1.2914855480*o.B, 3)
+	return rgbColor{
+		R: linearToSRGB(4.0767416621*l - 3.3077115913*m + 0.2309699292*s),
+		G: linearToSRGB(-1.2684380046*l + 2.6097574011*m - 0.3413193965*s),
+		B: linearToSRGB(-0.0041960863*l - 0.7034186147*m + 1.7076147010*s),
+	}
+}
+
+// gradientColors spreads n colors evenly along the stops, interpolating
+// in OKLab so the steps look even
+func gradientColors(stops []rgbColor, n int) []rgbColor {
+	if n == 1 || len(stops) == 1 {
+		colors := make([]rgbColor, n)
+		for i := range colors {
+			colors[i] = stops[0]
+		}
+		return colors
+	}
+
+	colors := make([]rgbColor, n)
+	segments := float64(len(stops) - 1)
+	for i := range colors {
+		pos := float64(i) / float64(n-1) * segments
+		seg := int(math.Min(math.Floor(pos), segments-1))
+		t := pos - float64(seg)
+		a, b := stops[seg].toOKLab(), stops[seg+1].toOKLab()
+		colors[i] = oklabColor{
+			L: a.L + (b.L-a.L)*t,
+			A: a.A + (b.A-a.A)*t,
+			B: a.B + (b.B-a.B)*t,
+		}.toRGB()
+	}
+	return colors
+}
+
+// toXY converts to a CIE xy color point using the wide-gamut conversion
+// Philips documents for Hue lights; the bridge maps it into each light's gamut
+func (c rgbColor) toXY() [2]float64 {
+	r, g, b := srgbToLinear(c.R), srgbToLinear(c.G), srgbToLinear(c.B)
+	x := r*0.664511 + g*0.154324 + b*0.162028
+	y := r*0.283881 + g*0.668433 + b*0.047685
+	z := r*0.000088 + g*0.072310 + b*0.986039
+	sum := x + y + z
+	if sum == 0 {
+		// Black has no chromaticity; use the white point
+		return [2]float64{0.3127, 0.3290}
+	}
+	return [2]float64{math.Round(x/sum*10000) / 10000, math.Round(y/sum*10000) / 10000}
+}
+
+// setRoomColors gives each color-capable light in the room its own color:
+// the palette repeated in order, or a gradient spread across the lights.
+// Lights are taken in the room's order, or shuffled if random is set.
+// Lights without color only get the brightness; their names are returned.
+func setRoomColors(config *Config, roomName string, brightness int, colors []rgbColor, gradient, random bool) (int, []string, error) {
+	lights, err := getLights(config)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	var lightIDs []string
+	if strings.ToLower(roomName) == "all" {
+		lightIDs = sortedIDs(lights)
+	} else {
+		groups, err := getGroups(config)
+		if err != nil {
+			return 0, nil, err
+		}
+		groupID, ok := findGroup(groups, roomName)
+		if !ok {
+			return 0, nil, fmt.Errorf("room '%s' not found. Use 'hue-control list' to see available rooms", roomName)
+		}
+		lightIDs = groups[groupID].Lights
+	}
+
+	var colorIDs, plainIDs []string
+	for _, id := range lightIDs {
+		if light, ok := lights[id]; ok && light.supportsColor() {
+			colorIDs = append(colorIDs, id)
+		} else if ok {
+			plainIDs = append(plainIDs, id)
+		}
+	}
+	if len(colorIDs) == 0 {
+		return 0, nil, fmt.Errorf("no lights in '%s' support color", roomName)
+	}
+	if random {
+		rand.Shuffle(len(colorIDs), func(i, j int) { colorIDs[i], colorIDs[j] = colorIDs[j], colorIDs[i] })
+	}
+	if gradient {
+		colors = gradientColors(colors, len(colorIDs))
+	}
+
+	for i, id := range colorIDs {
+		state := map[string]interface{}{
+			"on":  true,
+			"bri": brightness,
+			"xy":  colors[i%len(colors)].toXY(),
+		}
+		if err := setLightState(config, id, state); err != nil {
+			return i, nil, fmt.Errorf("failed to set '%s': %v", lights[id].Name, err)
+		}
+	}
+
+	var plain []string
+	for _, id := range plainIDs {
+		state := map[string]interface{}{"on": true}
+		if lights[id].dimmable() {
+			state["bri"] = brightness
+		}
+		if err := setLightState(config, id, state); err != nil {
+			return len(colorIDs), nil, fmt.Errorf("failed to set '%s': %v", lights[id].Name, err)
+		}
+		plain = append(plain, lights[id].Name)
+	}
+	return len(colorIDs), plain, nil
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// near reports whether two colors match to within tolerance per channel
+func near(a, b rgbColor, tolerance float64) bool {
+	return math.Abs(a.R-b.R) <= tolerance && math.Abs(a.G-b.G) <= tolerance && math.Abs(a.B-b.B) <= tolerance
+}
+
+func TestOKLab(t *testing.T) {
+	tests := []struct {
+		rgb rgbColor
+		lab oklabColor
+	}{
+		{rgbColor{1, 1, 1}, oklabColor{1, 0, 0}},
+		{rgbColor{0, 0, 0}, oklabColor{0, 0, 0}},
+		{rgbColor{1, 0, 0}, oklabColor{0.62796, 0.22486, 0.12585}},
+		{rgbColor{0, 1, 0}, oklabColor{0.86644, -0.23389, 0.17950}},
+		{rgbColor{0, 0, 1}, oklabColor{0.45201, -0.03246, -0.31153}},
+	}
+	for _, tt := range tests {
+		got := tt.rgb.toOKLab()
+		if math.Abs(got.L-tt.lab.L) > 1e-4 || math.Abs(got.A-tt.lab.A) > 1e-4 || math.Abs(got.B-tt.lab.B) > 1e-4 {
+			t.Errorf("%+v.toOKLab() = %+v, want %+v", tt.rgb, got, tt.lab)
+		}
+		if back := got.toRGB(); !near(back, tt.rgb, 1e-6) {
+			t.Errorf("%+v: round trip through OKLab gives %+v", tt.rgb, back)
+		}
+	}
+}
+
+func TestGradientColors(t *testing.T) {
+	black, white := rgbColor{0, 0, 0}, rgbColor{1, 1, 1}
+	red, blue := rgbColor{1, 0, 0}, rgbColor{0, 0, 1}
+	// OKLab lightness 0.5 is a mid gray, darker than sRGB 0.5
+	gray := rgbColor{0.3885, 0.3885, 0.3885}
+
+	tests := []struct {
+		name  string
+		stops []rgbColor
+		n     int
+		want  []rgbColor
+	}{
+		{"one light", []rgbColor{red, blue}, 1, []rgbColor{red}},
+		{"one stop", []rgbColor{red}, 3, []rgbColor{red, red, red}},
+		{"ends only", []rgbColor{black, white}, 2, []rgbColor{black, white}},
+		{"midpoint", []rgbColor{black, white}, 3, []rgbColor{black, gray, white}},
+		{"lights on the stops", []rgbColor{red, white, blue}, 5, nil},
+	}
+	for _, tt := range tests {
+		got := gradientColors(tt.stops, tt.n)
+		if len(got) != tt.n {
+			t.Errorf("%s: got %d colors, want %d", tt.name, len(got), tt.n)
+			continue
+		}
+		if tt.want == nil {
+			// Every other light sits exactly on a stop
+			for i, stop := range tt.stops {
+				if !near(got[2*i], stop, 1e-6) {
+					t.Errorf("%s: color %d = %+v, want the stop %+v", tt.name, 2*i, got[2*i], stop)
+				}
+			}
+			continue
+		}
+		for i := range got {
+			if !near(got[i], tt.want[i], 1e-3) {
+				t.Errorf("%s: color %d = %+v, want %+v", tt.name, i, got[i], tt.want[i])
+			}
+		}
+	}
+}
+
+func TestParseColor(t *testing.T) {
+	tests := []struct {
+		in   string
+		want rgbColor
+	}{
+		{"#ff8800", rgbColor{1, 136.0 / 255, 0}},
+		{"ff8800", rgbColor{1, 136.0 / 255, 0}},
+		{"#f80", rgbColor{1, 136.0 / 255, 0}},
+		{" #000000 ", rgbColor{0, 0, 0}},
+		{"Red", rgbColor{1, 0, 0}},
+	}
+	for _, tt := range tests {
+		got, err := parseColor(tt.in)
+		if err != nil || !near(got, tt.want, 1e-9) {
+			t.Errorf("parseColor(%q) = %+v, %v, want %+v", tt.in, got, err, tt.want)
+		}
+	}
+
+	for _, in := range []string{"", "#ff88", "#gg8800", "#ff880000", "mauve"} {
+		if _, err := parseColor(in); err == nil {
+			t.Errorf("parseColor(%q): no error", in)
+		}
+	}
+}
+
+func TestResolvePalette(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", dir)
+	path, err := palettesPath()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		t.Fatal(err)
+	}
+	data := `{"palettes": {"Party": ["#ff0000", "#00ff00"], "ocean": ["#0000ff"]}}`
+	if err := os.WriteFile(path, []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		spec string
+		want int // number of colors, or -1 for an error
+	}{
+		{"party", 2},
+		{"ocean", 1}, // the user palette replaces the built-in one
+		{"sunset", 4},
+		{"#ff0000, #0000ff,", 2},
+		{"nope", -1},
+		{"#ff0000,nope", -1},
+	}
+	for _, tt := range tests {
+		colors, err := resolvePalette(tt.spec)
+		switch {
+		case tt.want < 0 && err == nil:
+			t.Errorf("resolvePalette(%q): no error", tt.spec)
+		case tt.want >= 0 && (err != nil || len(colors) != tt.want):
+			t.Errorf("resolvePalette(%q) = %d colors, %v, want %d colors", tt.spec, len(colors), err, tt.want)
+		}
+	}
+
+	if _, err := resolveGradient("#ff0000"); err == nil || !strings.Contains(err.Error(), "at least two colors") {
+		t.Errorf("resolveGradient with one color: got %v", err)
+	}
+	if stops, err := resolveGradient("#ff0000..#ffff00..#0000ff"); err != nil || len(stops) != 3 {
+		t.Errorf("resolveGradient with three colors = %d stops, %v", len(stops), err)
+	}
+}
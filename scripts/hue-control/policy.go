@@ -0,0 +1,756 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// policyFile is the safety policy enforced on every change sent to the
+// bridge, meant for when an agent is the one running hue-control
+type policyFile struct {
+	ProtectedRooms       []string      `json:"protected_rooms"`
+	Limits               []policyLimit `json:"limits"`
+	MaxCommandsPerMinute int           `json:"max_commands_per_minute"`
+
+	loc *geoLocation // for limits using sun times
+}
+
+// policyLimit restricts brightness and color during a time window, e.g.
+// no bright blue from 22:00 to 07:00
+type policyLimit struct {
+	From          string   `json:"from"` // HH:MM or a sun time such as sunset-30m
+	To            string   `json:"to"`
+	Rooms         []string `json:"rooms"`          // empty for all rooms
+	MaxBrightness int      `json:"max_brightness"` // percent, 0 for no limit
+	DenyColors    []string `json:"deny_colors"`    // presets or hex colors; similar colors are denied too
+	MaxKelvin     int      `json:"max_kelvin"`     // coolest white allowed, 0 for no limit
+
+	from, to timeSpec
+	deny     map[string][2]float64
+}
+
+// Policy rules, as reported in PolicyError.Rule
+const (
+	policyProtectedRoom   = "protected-room"
+	policyBrightnessLimit = "brightness-limit"
+	policyColorLimit      = "color-limit"
+	policyRateLimit       = "rate-limit"
+	policyInvalid         = "invalid-policy"
+)
+
+// policyColorRadius is how close (in xy) a color may get to a denied color
+const policyColorRadius = 0.1
+
+// PolicyError is returned when the policy denies a change. Nothing of the
+// denied command has been sent to the bridge.
+type PolicyError struct {
+	Rule   string `json:"rule"`
+	Target string `json:"target,omitempty"` // room or light
+	Reason string `json:"reason"`
+}
+
+func (e *PolicyError) Error() string {
+	return fmt.Sprintf("denied by policy (%s): %s", e.Rule, e.Reason)
+}
+
+// policyPath returns HUE_POLICY, or the user's policy.json. explicit is
+// true if the file was configured and so must exist.
+func policyPath() (string, bool, error) {
+	if path := configValue("HUE_POLICY"); path != "" {
+		return path, true, nil
+	}
+	path, err := userFilePath("policy.json")
+	return path, false, err
+}
+
+// loadPolicy reads and checks the policy file; no file means no policy
+func loadPolicy() (*policyFile, error) {
+	path, explicit, err := policyPath()
+	if err != nil {
+		return nil, err
+	}
+	return loadPolicyFile(path, explicit)
+}
+
+// loadPolicyFile reads and checks the policy file at path
+func loadPolicyFile(path string, explicit bool) (*policyFile, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) && !explicit {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var policy policyFile
+	if err := json.Unmarshal(data, &policy); err != nil {
+		return nil, fmt.Errorf("invalid policy file %s: %v", path, err)
+	}
+	if policy.MaxCommandsPerMinute < 0 {
+		return nil, fmt.Errorf("policy %s: max_commands_per_minute can't be negative", path)
+	}
+	sunRelative := false
+	for i := range policy.Limits {
+		l := &policy.Limits[i]
+		if l.from, err = parseTimeSpec(l.From); err != nil {
+			return nil, fmt.Errorf("policy %s, limit %d: %v", path, i+1, err)
+		}
+		if l.to, err = parseTimeSpec(l.To); err != nil {
+			return nil, fmt.Errorf("policy %s, limit %d: %v", path, i+1, err)
+		}
+		sunRelative = sunRelative || l.from.sunRelative() || l.to.sunRelative()
+		if l.MaxBrightness < 0 || l.MaxBrightness > 100 {
+			return nil, fmt.Errorf("policy %s, limit %d: max_brightness must be between 0 and 100", path, i+1)
+		}
+		if l.MaxKelvin != 0 && (l.MaxKelvin < 2000 || l.MaxKelvin > 6500) {
+			return nil, fmt.Errorf("policy %s, limit %d: max_kelvin must be between 2000 and 6500", path, i+1)
+		}
+		l.deny = map[string][2]float64{}
+		for _, c := range l.DenyColors {
+			xy, err := policyColorXY(c)
+			if err != nil {
+				return nil, fmt.Errorf("policy %s, limit %d: %v", path, i+1, err)
+			}
+			l.deny[c] = xy
+		}
+	}
+	if sunRelative {
+		lat, lon, err := configLocation("", "")
+		if err != nil {
+			return nil, fmt.Errorf("policy %s uses sun times: %v", path, err)
+		}
+		policy.loc = &geoLocation{Lat: lat, Lon: lon}
+	}
+	return &policy, nil
+}
+
+// policyColorXY returns the xy color point of a preset or hex color
+func policyColorXY(spec string) ([2]float64, error) {
+	if preset, ok := colorPresets()[strings.ToLower(strings.TrimSpace(spec))]; ok {
+		return preset.rgb().toXY(), nil
+	}
+	c, err := parseHexColor(spec)
+	if err != nil {
+		return [2]float64{}, fmt.Errorf("unknown color '%s'. Use a hex color or one of: %s", spec, presetNames())
+	}
+	return c.toXY(), nil
+}
+
+var (
+	policyLoadMu    sync.Mutex
+	policyLoaded    bool
+	policyFileKey   string // path, size and modification time of the loaded file
+	loadedPolicy    *policyFile
+	loadedPolicyErr error
+
+	policyMu    sync.Mutex
+	policyCache policySnapshot
+)
+
+// activePolicy returns the policy; nil means no policy. The file is read
+// again when it changes, so servers such as mcp and webhook follow edits
+// without a restart.
+func activePolicy(config *Config) (*policyFile, error) {
+	path, explicit, pathErr := config.policy.policyPath()
+	key := ""
+	if pathErr == nil {
+		key = path
+		if info, err := os.Stat(path); err == nil {
+			key = fmt.Sprintf("%s %d %d", path, info.Size(), info.ModTime().UnixNano())
+		}
+	}
+
+	policyLoadMu.Lock()
+	defer policyLoadMu.Unlock()
+	if !policyLoaded || key != policyFileKey {
+		if pathErr != nil {
+			loadedPolicy, loadedPolicyErr = nil, pathErr
+		} else {
+			loadedPolicy, loadedPolicyErr = loadPolicyFile(path, explicit)
+		}
+		policyLoaded, policyFileKey = true, key
+	}
+	return loadedPolicy, loadedPolicyErr
+}
+
+// policyCommand is one command as counted by the rate budget: only its
+// first allowed write is counted, however many lights it changes. It also
+// keeps what a command's checks look up only once: where the policy file
+// is, and the v1 light behind each v2 light ID.
+type policyCommand struct {
+	mu    sync.Mutex
+	spent bool
+
+	located  bool
+	path     string
+	explicit bool
+	pathErr  error
+
+	v2Lights map[string]string // v2 light ID -> v1 state path
+}
+
+// policyPath is policyPath, resolved once per command
+func (cmd *policyCommand) policyPath() (string, bool, error) {
+	if cmd == nil {
+		return policyPath()
+	}
+	cmd.mu.Lock()
+	defer cmd.mu.Unlock()
+	if !cmd.located {
+		cmd.path, cmd.explicit, cmd.pathErr = policyPath()
+		cmd.located = true
+	}
+	return cmd.path, cmd.explicit, cmd.pathErr
+}
+
+// v2LightStatePath returns the v1 state path of a v2 light, or "" if the
+// bridge has no such light. The bridge's lights are read once per command.
+func v2LightStatePath(config *Config, id string) (string, error) {
+	cmd := config.policy
+	if cmd != nil {
+		cmd.mu.Lock()
+		paths := cmd.v2Lights
+		cmd.mu.Unlock()
+		if paths != nil {
+			return paths[id], nil
+		}
+	}
+
+	// Not holding the lock: reading from the bridge checks the policy too
+	var lights []v2Light
+	if err := bridgeV2Request(config, "GET", "/resource/light", nil, &lights); err != nil {
+		return "", err
+	}
+	paths := map[string]string{}
+	for _, l := range lights {
+		if l.IDV1 != "" {
+			paths[l.ID] = l.IDV1 + "/state"
+		}
+	}
+	if cmd != nil {
+		cmd.mu.Lock()
+		cmd.v2Lights = paths
+		cmd.mu.Unlock()
+	}
+	return paths[id], nil
+}
+
+// beginPolicyCommand returns a copy of config whose writes count as one new
+// command. loadConfig starts one for the whole process, which suits one-shot
+// commands; servers start one for every request and daemons for every
+// change they make. Writes through a config without one are each counted.
+func beginPolicyCommand(config *Config) *Config {
+	c := *config
+	c.policy = &policyCommand{}
+	return &c
+}
+
+// checkPolicy decides whether a write to the bridge is allowed, and counts
+// the first allowed write of each command against the rate budget. path is
+// a v1 resource path such as "/groups/1/action".
+func checkPolicy(config *Config, method, path string, body interface{}) error {
+	if err := policyAllows(config, method, path, body); err != nil {
+		return err
+	}
+	policy, _ := activePolicy(config)
+	if policy == nil || policy.MaxCommandsPerMinute == 0 || method == "GET" {
+		return nil
+	}
+
+	cmd := config.policy
+	if cmd == nil {
+		return spendPolicyBudget(policy.MaxCommandsPerMinute)
+	}
+	cmd.mu.Lock()
+	defer cmd.mu.Unlock()
+	if cmd.spent {
+		return nil
+	}
+	if err := spendPolicyBudget(policy.MaxCommandsPerMinute); err != nil {
+		return err
+	}
+	cmd.spent = true
+	return nil
+}
+
+// policyAllows checks a write against protected rooms and the limits
+// active now, without using the rate budget
+func policyAllows(config *Config, method, path string, body interface{}) error {
+	policy, err := activePolicy(config)
+	if err != nil {
+		// A broken policy must not mean no policy
+		return &PolicyError{Rule: policyInvalid, Reason: err.Error()}
+	}
+	if policy == nil || method == "GET" {
+		return nil
+	}
+
+	state := map[string]interface{}{}
+	if body != nil {
+		data, _ := json.Marshal(body)
+		json.Unmarshal(data, &state)
+	}
+
+	snap, err := policySnapshotFor(config)
+	if err != nil {
+		return fmt.Errorf("failed to check policy: %v", err)
+	}
+
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	switch {
+	case len(parts) >= 2 && parts[0] == "lights":
+		if err := policy.checkProtected(snap, "", []string{parts[1]}); err != nil {
+			return err
+		}
+		if len(parts) == 3 && parts[2] == "state" {
+			return policy.checkLimits(snap, []string{parts[1]}, state)
+		}
+
+	case len(parts) >= 2 && parts[0] == "groups":
+		groupID := parts[1]
+		lightIDs := sortedIDs(snap.lights)
+		if groupID != "0" {
+			lightIDs = snap.groups[groupID].Lights
+		}
+		if err := policy.checkProtected(snap, groupID, lightIDs); err != nil {
+			return err
+		}
+		if len(parts) != 3 || parts[2] != "action" {
+			return nil
+		}
+		if sceneID, ok := state["scene"].(string); ok {
+			return policy.checkScene(config, snap, sceneID)
+		}
+		return policy.checkLimits(snap, lightIDs, state)
+
+	case len(parts) >= 1 && parts[0] == "rules" && method != "DELETE":
+		// Rules run later, so only protected rooms can be checked now
+		var rule struct {
+			Actions []RuleAction `json:"actions"`
+		}
+		data, _ := json.Marshal(body)
+		json.Unmarshal(data, &rule)
+		for _, action := range rule.Actions {
+			target := strings.Split(strings.Trim(action.Address, "/"), "/")
+			if len(target) >= 2 && target[0] == "lights" {
+				if err := policy.checkProtected(snap, "", []string{target[1]}); err != nil {
+					return err
+				}
+			}
+			if len(target) >= 2 && target[0] == "groups" {
+				lightIDs := sortedIDs(snap.lights)
+				if target[1] != "0" {
+					lightIDs = snap.groups[target[1]].Lights
+				}
+				if err := policy.checkProtected(snap, target[1], lightIDs); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// checkPolicyV2 checks a write to a v2 light resource by translating it to
+// the v1 light and state fields it corresponds to
+func checkPolicyV2(config *Config, method, path string, body interface{}) error {
+	policy, err := activePolicy(config)
+	if err != nil {
+		return &PolicyError{Rule: policyInvalid, Reason: err.Error()}
+	}
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	if policy == nil || method == "GET" || len(parts) != 3 || parts[0] != "resource" || parts[1] != "light" {
+		return nil
+	}
+
+	lightPath, err := v2LightStatePath(config, parts[2])
+	if err != nil {
+		return fmt.Errorf("failed to check policy: %v", err)
+	}
+	if lightPath == "" {
+		return nil
+	}
+
+	var v2 struct {
+		On *struct {
+			On bool `json:"on"`
+		} `json:"on"`
+		Dimming *struct {
+			Brightness float64 `json:"brightness"`
+		} `json:"dimming"`
+		Color *struct {
+			XY struct{ X, Y float64 } `json:"xy"`
+		} `json:"color"`
+		Gradient *struct {
+			Points []struct {
+				Color struct {
+					XY struct{ X, Y float64 } `json:"xy"`
+				} `json:"color"`
+			} `json:"points"`
+		} `json:"gradient"`
+	}
+	data, _ := json.Marshal(body)
+	json.Unmarshal(data, &v2)
+
+	state := map[string]interface{}{}
+	if v2.On != nil {
+		state["on"] = v2.On.On
+	}
+	if v2.Dimming != nil {
+		state["bri"] = dimmingToBri(v2.Dimming.Brightness)
+	}
+	var colors [][2]float64
+	if v2.Color != nil {
+		colors = append(colors, [2]float64{v2.Color.XY.X, v2.Color.XY.Y})
+	}
+	if v2.Gradient != nil {
+		for _, p := range v2.Gradient.Points {
+			colors = append(colors, [2]float64{p.Color.XY.X, p.Color.XY.Y})
+		}
+	}
+	if len(colors) == 0 {
+		return checkPolicy(config, "PUT", lightPath, state)
+	}
+	// Every gradient point must be allowed
+	for _, xy := range colors {
+		state["xy"] = xy
+		if err := policyAllows(config, "PUT", lightPath, state); err != nil {
+			return err
+		}
+	}
+	return checkPolicy(config, "PUT", lightPath, state)
+}
+
+// policySnapshot is the bridge's rooms and lights, reused for a moment so
+// a command setting many lights doesn't read them for every light
+type policySnapshot struct {
+	groups map[string]Group
+	lights map[string]Light
+	at     time.Time
+}
+
+func policySnapshotFor(config *Config) (policySnapshot, error) {
+	policyMu.Lock()
+	defer policyMu.Unlock()
+	if policyCache.groups != nil && time.Since(policyCache.at) < 2*time.Second {
+		return policyCache, nil
+	}
+	groups, err := getGroups(config)
+	if err != nil {
+		return policySnapshot{}, err
+	}
+	lights, err := getLights(config)
+	if err != nil {
+		return policySnapshot{}, err
+	}
+	policyCache = policySnapshot{groups: groups, lights: lights, at: time.Now()}
+	return policyCache, nil
+}
+
+// checkProtected denies changes to a protected group or to any light in one
+func (p *policyFile) checkProtected(snap policySnapshot, groupID string, lightIDs []string) error {
+	for _, name := range p.ProtectedRooms {
+		id, ok := findGroup(snap.groups, name)
+		if !ok {
+			continue
+		}
+		room := snap.groups[id]
+		if id == groupID {
+			return &PolicyError{Rule: policyProtectedRoom, Target: room.Name, Reason: fmt.Sprintf("%s is protected and can't be changed", room.Name)}
+		}
+		for _, lightID := range lightIDs {
+			for _, member := range room.Lights {
+				if member == lightID {
+					return &PolicyError{
+						Rule:   policyProtectedRoom,
+						Target: room.Name,
+						Reason: fmt.Sprintf("%s is in %s, which is protected and can't be changed", snap.lights[lightID].Name, room.Name),
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// checkScene checks the light states a scene would recall
+func (p *policyFile) checkScene(config *Config, snap policySnapshot, sceneID string) error {
+	var scene struct {
+		LightStates map[string]map[string]interface{} `json:"lightstates"`
+	}
+	if err := bridgeGet(config, "/scenes/"+sceneID, &scene); err != nil {
+		return fmt.Errorf("failed to check policy: %v", err)
+	}
+	for _, lightID := range sortedIDs(scene.LightStates) {
+		if err := p.checkLimits(snap, []string{lightID}, scene.LightStates[lightID]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkLimits checks the state each light would end up in against the
+// limits active now. Fields the state doesn't set keep the light's
+// current value, so turning on a light that was left bright is limited
+// too. Turning lights off is always allowed.
+func (p *policyFile) checkLimits(snap policySnapshot, lightIDs []string, state map[string]interface{}) error {
+	now := time.Now()
+	for _, limit := range p.Limits {
+		if !limit.active(now, p.loc) {
+			continue
+		}
+		window := fmt.Sprintf("from %s to %s", limit.From, limit.To)
+		for _, lightID := range lightIDs {
+			light, ok := snap.lights[lightID]
+			if !ok {
+				continue
+			}
+			room, applies := limit.appliesTo(snap, lightID)
+			if !applies {
+				continue
+			}
+			on, percent, xy, ct := effectiveState(light, state)
+			if !on {
+				continue
+			}
+			target := room
+			if target == "" {
+				target = light.Name
+			}
+
+			if limit.MaxBrightness > 0 && light.dimmable() && percent > limit.MaxBrightness {
+				return &PolicyError{
+					Rule:   policyBrightnessLimit,
+					Target: target,
+					Reason: fmt.Sprintf("%s is limited to %d%% brightness %s (asked for %d%%)", target, limit.MaxBrightness, window, percent),
+				}
+			}
+			if xy != nil {
+				for _, name := range limit.DenyColors {
+					d := limit.deny[name]
+					if math.Hypot(xy[0]-d[0], xy[1]-d[1]) < policyColorRadius {
+						return &PolicyError{
+							Rule:   policyColorLimit,
+							Target: target,
+							Reason: fmt.Sprintf("%s is not allowed in %s %s", name, target, window),
+						}
+					}
+				}
+			}
+			if limit.MaxKelvin > 0 && ct > 0 && ct < int(math.Round(1e6/float64(limit.MaxKelvin))) {
+				return &PolicyError{
+					Rule:   policyColorLimit,
+					Target: target,
+					Reason: fmt.Sprintf("%s is limited to %dK or warmer %s (asked for %dK)", target, limit.MaxKelvin, window, int(math.Round(1e6/float64(ct)))),
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// active reports whether now is inside the limit's window. Windows may
+// wrap past midnight; a sun time that doesn't happen today keeps the limit
+// active, as the policy should rather deny too much than too little.
+func (l policyLimit) active(now time.Time, loc *geoLocation) bool {
+	from, err := l.from.on(now, loc)
+	if err != nil {
+		return true
+	}
+	to, err := l.to.on(now, loc)
+	if err != nil {
+		return true
+	}
+	if to.After(from) {
+		return !now.Before(from) && now.Before(to)
+	}
+	return !now.Before(from) || now.Before(to)
+}
+
+// appliesTo reports whether a limit covers a light, and names the room
+// the light is limited as
+func (l policyLimit) appliesTo(snap policySnapshot, lightID string) (string, bool) {
+	if len(l.Rooms) == 0 {
+		for _, id := range sortedIDs(snap.groups) {
+			if g := snap.groups[id]; g.Type == "Room" && containsString(g.Lights, lightID) {
+				return g.Name, true
+			}
+		}
+		return "", true
+	}
+	for _, name := range l.Rooms {
+		if id, ok := findGroup(snap.groups, name); ok && containsString(snap.groups[id].Lights, lightID) {
+			return snap.groups[id].Name, true
+		}
+	}
+	return "", false
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// effectiveState returns whether a light would be on, its brightness in
+// percent, and its color as xy (nil if unknown) and color temperature
+// (0 if not a white) after the state is applied
+func effectiveState(light Light, state map[string]interface{}) (bool, int, *[2]float64, int) {
+	on := light.State.On
+	if v, ok := state["on"].(bool); ok {
+		on = v
+	}
+	bri := light.State.Bri
+	if v, ok := state["bri"]; ok {
+		bri = intValue(v)
+	}
+
+	var xy *[2]float64
+	ct := 0
+	_, hasHue := state["hue"]
+	_, hasSat := state["sat"]
+	switch {
+	case state["xy"] != nil:
+		if p, ok := xyValue(state["xy"]); ok {
+			xy = &p
+		}
+	case hasHue || hasSat:
+		x, y := requestedXY(light, state)
+		xy = &[2]float64{x, y}
+	case state["ct"] != nil:
+		ct = intValue(state["ct"])
+	case !light.supportsColor() && !light.supportsCT():
+	default:
+		switch light.State.ColorMode {
+		case "xy":
+			if len(light.State.XY) == 2 {
+				xy = &[2]float64{light.State.XY[0], light.State.XY[1]}
+			}
+		case "hs":
+			p := hueSatToRGB(light.State.Hue, light.State.Sat).toXY()
+			xy = &p
+		case "ct":
+			ct = light.State.CT
+		}
+	}
+	if ct > 0 && xy == nil {
+		x, y := ctToXY(ct)
+		xy = &[2]float64{x, y}
+	}
+	return on, briToPercent(bri), xy, ct
+}
+
+// policyUsageFile records recent commands for the rate budget, shared by
+// every hue-control process
+type policyUsageFile struct {
+	Commands []time.Time `json:"commands"`
+}
+
+// spendPolicyBudget records a command, or denies it if the last minute
+// already had max commands. The usage file is locked while it is updated
+// so parallel processes don't lose each other's commands.
+func spendPolicyBudget(max int) error {
+	path, err := userFilePath("policy-usage.json")
+	if err != nil {
+		return err
+	}
+	release, err := acquireFileLock("policy-usage.lock", 5*time.Second, 10*time.Second)
+	if err != nil {
+		return fmt.Errorf("failed to update rate budget: %v", err)
+	}
+	defer release()
+	var usage policyUsageFile
+	if data, err := os.ReadFile(path); err == nil {
+		json.Unmarshal(data, &usage)
+	}
+
+	now := time.Now()
+	var recent []time.Time
+	for _, t := range usage.Commands {
+		if now.Sub(t) < time.Minute {
+			recent = append(recent, t)
+		}
+	}
+	if len(recent) >= max {
+		wait := time.Minute - now.Sub(recent[len(recent)-max])
+		return &PolicyError{
+			Rule:   policyRateLimit,
+			Reason: fmt.Sprintf("at most %d commands per minute are allowed; try again in %ds", max, int(math.Ceil(wait.Seconds()))),
+		}
+	}
+	usage.Commands = append(recent, now)
+
+	data, err := json.Marshal(usage)
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func runPolicy() {
+	if len(os.Args) < 3 || os.Args[2] != "show" {
+		fmt.Println("Usage: hue-control policy show")
+		os.Exit(1)
+	}
+	policyCmd := flag.NewFlagSet("policy show", flag.ExitOnError)
+	policyCmd.Parse(os.Args[3:])
+
+	path, _, err := policyPath()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	policy, err := loadPolicy()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		fmt.Println("Every change is denied until the policy file is fixed.")
+		os.Exit(1)
+	}
+	if policy == nil {
+		fmt.Printf("No policy (%s does not exist); all changes are allowed\n", path)
+		return
+	}
+
+	fmt.Printf("Policy: %s\n", path)
+	if len(policy.ProtectedRooms) > 0 {
+		fmt.Printf("Protected rooms: %s\n", strings.Join(policy.ProtectedRooms, ", "))
+	}
+	now := time.Now()
+	for _, l := range policy.Limits {
+		rooms := "all rooms"
+		if len(l.Rooms) > 0 {
+			rooms = strings.Join(l.Rooms, ", ")
+		}
+		var rules []string
+		if l.MaxBrightness > 0 {
+			rules = append(rules, fmt.Sprintf("at most %d%%", l.MaxBrightness))
+		}
+		if len(l.DenyColors) > 0 {
+			rules = append(rules, "no "+strings.Join(l.DenyColors, "/"))
+		}
+		if l.MaxKelvin > 0 {
+			rules = append(rules, fmt.Sprintf("%dK or warmer", l.MaxKelvin))
+		}
+		status := ""
+		if l.active(now, policy.loc) {
+			status = " (active now)"
+		}
+		fmt.Printf("Limit %s-%s in %s: %s%s\n", l.From, l.To, rooms, strings.Join(rules, ", "), status)
+	}
+	if policy.MaxCommandsPerMinute > 0 {
+		fmt.Printf("Rate budget: %d commands per minute\n", policy.MaxCommandsPerMinute)
+	}
+}
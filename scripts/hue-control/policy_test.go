@@ -0,0 +1,163 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const testPolicy = `{
+  "protected_rooms": ["Nursery"],
+  "limits": [
+    {"from": "00:00", "to": "00:00", "rooms": ["Bedroom"], "max_brightness": 40, "deny_colors": ["blue"], "max_kelvin": 3000}
+  ]
+}`
+
+// usePolicy makes policy the active policy and the bridge state the one
+// the policy is checked against
+func usePolicy(t *testing.T, policy string) {
+	t.Helper()
+	dir := t.TempDir()
+	t.Setenv("HOME", dir)
+	t.Setenv("XDG_CONFIG_HOME", dir)
+	path := filepath.Join(dir, "policy.json")
+	if err := os.WriteFile(path, []byte(policy), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("HUE_POLICY", path)
+
+	color := func(name string, on bool, bri int) Light {
+		return Light{Name: name, Type: "Extended color light", State: LightState{On: on, Bri: bri, ColorMode: "ct", CT: 370, Reachable: true}}
+	}
+	policyMu.Lock()
+	policyCache = policySnapshot{
+		groups: map[string]Group{
+			"1": {Name: "Bedroom", Type: "Room", Lights: []string{"1"}},
+			"2": {Name: "Nursery", Type: "Room", Lights: []string{"2"}},
+			"3": {Name: "Office", Type: "Room", Lights: []string{"3"}},
+		},
+		lights: map[string]Light{
+			"1": color("Bed Lamp", true, 254),
+			"2": color("Crib Light", false, 100),
+			"3": color("Desk Lamp", false, 254),
+		},
+		at: time.Now().Add(time.Hour), // never refreshed during the test
+	}
+	policyMu.Unlock()
+}
+
+func TestPolicyAllows(t *testing.T) {
+	usePolicy(t, testPolicy)
+	config := &Config{}
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   interface{}
+		rule   string // "" if allowed
+	}{
+		{"dim enough", "PUT", "/lights/1/state", map[string]interface{}{"bri": 100}, ""},
+		{"too bright", "PUT", "/lights/1/state", map[string]interface{}{"bri": 200}, policyBrightnessLimit},
+		{"on at its last brightness", "PUT", "/lights/1/state", map[string]interface{}{"on": true}, policyBrightnessLimit},
+		{"off is always allowed", "PUT", "/lights/1/state", map[string]interface{}{"on": false}, ""},
+		{"denied color", "PUT", "/lights/1/state", map[string]interface{}{"bri": 50, "xy": []interface{}{0.16, 0.05}}, policyColorLimit},
+		{"allowed color", "PUT", "/lights/1/state", map[string]interface{}{"bri": 50, "xy": []interface{}{0.55, 0.4}}, ""},
+		{"too cool", "PUT", "/lights/1/state", map[string]interface{}{"bri": 50, "ct": 153}, policyColorLimit},
+		{"warm enough", "PUT", "/lights/1/state", map[string]interface{}{"bri": 50, "ct": 370}, ""},
+		{"room action", "PUT", "/groups/1/action", map[string]interface{}{"bri": 254}, policyBrightnessLimit},
+		{"other room", "PUT", "/groups/3/action", map[string]interface{}{"on": true, "bri": 254, "ct": 153}, ""},
+		{"protected room", "PUT", "/groups/2/action", map[string]interface{}{"on": false}, policyProtectedRoom},
+		{"light in protected room", "PUT", "/lights/2/state", map[string]interface{}{"on": true}, policyProtectedRoom},
+		{"all lights", "PUT", "/groups/0/action", map[string]interface{}{"on": false}, policyProtectedRoom},
+		{"rename protected light", "PUT", "/lights/2", map[string]interface{}{"name": "Crib"}, policyProtectedRoom},
+		{"rule for protected room", "POST", "/rules", map[string]interface{}{
+			"actions": []map[string]interface{}{{"address": "/groups/2/action", "method": "PUT", "body": map[string]interface{}{"on": true}}},
+		}, policyProtectedRoom},
+		{"reads", "GET", "/groups/2", nil, ""},
+	}
+	for _, tt := range tests {
+		err := policyAllows(config, tt.method, tt.path, tt.body)
+		var denied *PolicyError
+		switch {
+		case tt.rule == "" && err != nil:
+			t.Errorf("%s: denied: %v", tt.name, err)
+		case tt.rule != "" && !errors.As(err, &denied):
+			t.Errorf("%s: got %v, want a %s denial", tt.name, err, tt.rule)
+		case tt.rule != "" && denied.Rule != tt.rule:
+			t.Errorf("%s: denied by %s, want %s", tt.name, denied.Rule, tt.rule)
+		}
+	}
+}
+
+func TestPolicyInvalidDeniesEverything(t *testing.T) {
+	usePolicy(t, `{"limits": [{"from": "25:00", "to": "07:00"}]}`)
+
+	err := policyAllows(&Config{}, "PUT", "/groups/3/action", map[string]interface{}{"on": false})
+	var denied *PolicyError
+	if !errors.As(err, &denied) || denied.Rule != policyInvalid {
+		t.Errorf("got %v, want an %s denial", err, policyInvalid)
+	}
+}
+
+func TestPolicyRateBudget(t *testing.T) {
+	usePolicy(t, `{"max_commands_per_minute": 2}`)
+	base := &Config{}
+	write := func(config *Config) error {
+		return checkPolicy(config, "PUT", "/lights/3/state", map[string]interface{}{"on": true})
+	}
+
+	// A command is counted once, however many writes it makes
+	first := beginPolicyCommand(base)
+	for i := 0; i < 3; i++ {
+		if err := write(first); err != nil {
+			t.Fatalf("write %d of the first command: %v", i+1, err)
+		}
+	}
+	if err := write(beginPolicyCommand(base)); err != nil {
+		t.Fatalf("second command: %v", err)
+	}
+	var denied *PolicyError
+	if err := write(beginPolicyCommand(base)); !errors.As(err, &denied) || denied.Rule != policyRateLimit {
+		t.Errorf("third command: got %v, want a %s denial", err, policyRateLimit)
+	}
+	// Writes without a command are each counted
+	if err := write(base); !errors.As(err, &denied) {
+		t.Errorf("write without a command: got %v, want a denial", err)
+	}
+}
+
+func TestPolicyLimitActive(t *testing.T) {
+	day := time.Date(2026, 3, 10, 0, 0, 0, 0, time.UTC)
+	at := func(hour, minute int) time.Time {
+		return day.Add(time.Duration(hour)*time.Hour + time.Duration(minute)*time.Minute)
+	}
+
+	tests := []struct {
+		from, to string
+		now      time.Time
+		want     bool
+	}{
+		{"22:00", "07:00", at(23, 0), true},
+		{"22:00", "07:00", at(6, 59), true},
+		{"22:00", "07:00", at(7, 0), false},
+		{"22:00", "07:00", at(12, 0), false},
+		{"09:00", "17:00", at(12, 0), true},
+		{"09:00", "17:00", at(17, 30), false},
+	}
+	for _, tt := range tests {
+		var l policyLimit
+		var err error
+		if l.from, err = parseTimeSpec(tt.from); err != nil {
+			t.Fatal(err)
+		}
+		if l.to, err = parseTimeSpec(tt.to); err != nil {
+			t.Fatal(err)
+		}
+		if got := l.active(tt.now, nil); got != tt.want {
+			t.Errorf("%s-%s at %s: active = %v, want %v", tt.from, tt.to, tt.now.Format("15:04"), got, tt.want)
+		}
+	}
+}
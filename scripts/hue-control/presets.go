@@ -0,0 +1,404 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+)
+
+// Preset is a named color, defined by exactly one of Hex, XY, CT (mireds)
+// or Hue and Sat, optionally with a brightness percentage
+type Preset struct {
+	Hex        string    `json:"hex,omitempty"`
+	XY         []float64 `json:"xy,omitempty"`
+	CT         int       `json:"ct,omitempty"`
+	Hue        *int      `json:"hue,omitempty"`
+	Sat        *int      `json:"sat,omitempty"`
+	Brightness *int      `json:"brightness,omitempty"`
+}
+
+func hueSatPreset(hue, sat int) Preset {
+	return Preset{Hue: &hue, Sat: &sat}
+}
+
+// builtinPresets are the color presets available without any configuration
+var builtinPresets = map[string]Preset{
+	"red":    hueSatPreset(0, 254),
+	"orange": hueSatPreset(5000, 254),
+	"yellow": hueSatPreset(10000, 254),
+	"green":  hueSatPreset(25500, 254),
+	"cyan":   hueSatPreset(35000, 254),
+	"blue":   hueSatPreset(46920, 254),
+	"purple": hueSatPreset(50000, 254),
+	"pink":   hueSatPreset(56100, 254),
+	"warm":   hueSatPreset(8000, 200), // Warm white
+	"cool":   hueSatPreset(34000, 50), // Cool white
+	"white":  hueSatPreset(0, 0),      // Pure white (no color)
+}
+
+// presetsFile is the user's presets file, e.g.
+// {"presets": {"candle": {"ct": 454, "brightness": 30}, "red": {"hex": "#e0201a"}}}
+type presetsFile struct {
+	Presets map[string]Preset `json:"presets"`
+}
+
+func presetsPath() (string, error) {
+	return userFilePath("presets.json")
+}
+
+// loadUserPresets reads the user's presets file; a missing file has no presets
+func loadUserPresets() (map[string]Preset, error) {
+	presets := map[string]Preset{}
+	path, err := presetsPath()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return presets, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var file presetsFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("invalid presets file %s: %v", path, err)
+	}
+	for name, p := range file.Presets {
+		if err := p.validate(); err != nil {
+			return nil, fmt.Errorf("preset '%s' in %s: %v", name, path, err)
+		}
+		presets[strings.ToLower(name)] = p
+	}
+	return presets, nil
+}
+
+// saveUserPresets writes the user's presets file through a temporary file
+func saveUserPresets(presets map[string]Preset) error {
+	path, err := presetsPath()
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(presetsFile{Presets: presets}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+var (
+	presetsOnce   sync.Once
+	loadedPresets map[string]Preset
+)
+
+// colorPresets returns the built-in presets merged with the user's presets
+// file, where a user preset replaces a built-in one of the same name. A
+// broken presets file is reported once and ignored.
+func colorPresets() map[string]Preset {
+	presetsOnce.Do(func() {
+		loadedPresets = map[string]Preset{}
+		for name, p := range builtinPresets {
+			loadedPresets[name] = p
+		}
+		user, err := loadUserPresets()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: ignoring user presets: %v\n", err)
+			return
+		}
+		for name, p := range user {
+			loadedPresets[name] = p
+		}
+	})
+	return loadedPresets
+}
+
+// presetNames returns the available preset names for error messages
+func presetNames() string {
+	return strings.Join(sortedIDs(colorPresets()), ", ")
+}
+
+func (p Preset) validate() error {
+	forms := 0
+	if p.Hex != "" {
+		forms++
+		if _, err := parseHexColor(p.Hex); err != nil {
+			return err
+		}
+	}
+	if p.XY != nil {
+		forms++
+		if len(p.XY) != 2 || p.XY[0] < 0 || p.XY[0] > 1 || p.XY[1] < 0 || p.XY[1] > 1 {
+			return fmt.Errorf("xy must be two numbers between 0 and 1")
+		}
+	}
+	if p.CT != 0 {
+		forms++
+		if p.CT < 153 || p.CT > 500 {
+			return fmt.Errorf("ct must be between 153 and 500 mireds")
+		}
+	}
+	if p.Hue != nil || p.Sat != nil {
+		forms++
+		if p.Hue == nil || p.Sat == nil {
+			return fmt.Errorf("hue and sat must be given together")
+		}
+		if *p.Hue < 0 || *p.Hue > 65535 || *p.Sat < 0 || *p.Sat > 254 {
+			return fmt.Errorf("hue must be 0-65535 and sat 0-254")
+		}
+	}
+	if forms != 1 {
+		return fmt.Errorf("give exactly one of hex, xy, ct or hue/sat")
+	}
+	if p.Brightness != nil && (*p.Brightness < 0 || *p.Brightness > 100) {
+		return fmt.Errorf("brightness must be between 0 and 100")
+	}
+	return nil
+}
+
+// state returns the bridge state fields that set the preset's color
+func (p Preset) state() map[string]interface{} {
+	switch {
+	case p.Hex != "", p.XY != nil:
+		return map[string]interface{}{"xy": p.rgb().toXY()}
+	case p.CT != 0:
+		return map[string]interface{}{"ct": p.CT}
+	default:
+		return map[string]interface{}{"hue": *p.Hue, "sat": *p.Sat}
+	}
+}
+
+// rgb returns the preset's color as sRGB
+func (p Preset) rgb() rgbColor {
+	switch {
+	case p.Hex != "":
+		c, _ := parseHexColor(p.Hex)
+		return c
+	case p.XY != nil:
+		return linearRGB(xyToLinearRGB(p.XY[0], p.XY[1]))
+	case p.CT != 0:
+		x, y := ctToXY(p.CT)
+		return linearRGB(xyToLinearRGB(x, y))
+	default:
+		return hueSatToRGB(*p.Hue, *p.Sat)
+	}
+}
+
+// describe returns e.g. "hex #ff8800", "ct 370 (2703K) at 40%"
+func (p Preset) describe() string {
+	var desc string
+	switch {
+	case p.Hex != "":
+		desc = "hex " + p.Hex
+	case p.XY != nil:
+		desc = fmt.Sprintf("xy %.4f,%.4f", p.XY[0], p.XY[1])
+	case p.CT != 0:
+		desc = fmt.Sprintf("ct %d (%dK)", p.CT, int(math.Round(1e6/float64(p.CT))))
+	default:
+		desc = fmt.Sprintf("hue %d sat %d", *p.Hue, *p.Sat)
+	}
+	if p.Brightness != nil {
+		desc += fmt.Sprintf(" at %d%%", *p.Brightness)
+	}
+	return desc
+}
+
+func linearRGB(r, g, b float64) rgbColor {
+	return rgbColor{R: linearToSRGB(r), G: linearToSRGB(g), B: linearToSRGB(b)}
+}
+
+// ctToXY returns the point on the black body curve for a color temperature
+// in mireds, using Kim et al.'s cubic approximation
+func ctToXY(ct int) (float64, float64) {
+	t := 1e6 / float64(ct)
+	var x float64
+	if t <= 4000 {
+		x = -0.2661239e9/(t*t*t) - 0.2343589e6/(t*t) + 0.8776956e3/t + 0.179910
+	} else {
+		x = -3.0258469e9/(t*t*t) + 2.1070379e6/(t*t) + 0.2226347e3/t + 0.240390
+	}
+	var y float64
+	switch {
+	case t <= 2222:
+		y = -1.1063814*x*x*x - 1.34811020*x*x + 2.18555832*x - 0.20219683
+	case t <= 4000:
+		y = -0.9549476*x*x*x - 1.37418593*x*x + 2.09137015*x - 0.16748867
+	default:
+		y = 3.0817580*x*x*x - 5.87338670*x*x + 3.75112997*x - 0.37001483
+	}
+	return x, y
+}
+
+func runPreset() {
+	if len(os.Args) < 3 {
+		printPresetUsage()
+		os.Exit(1)
+	}
+
+	switch os.Args[2] {
+	case "list":
+		runPresetList()
+	case "add":
+		runPresetAdd()
+	case "remove":
+		runPresetRemove()
+	default:
+		fmt.Printf("Unknown preset command: %s\n", os.Args[2])
+		printPresetUsage()
+		os.Exit(1)
+	}
+}
+
+func printPresetUsage() {
+	fmt.Println(`Usage:
+  hue-control preset list [--json]
+  hue-control preset add <name> (--hex <#rrggbb> | --xy <x,y> | --ct <153-500> | --hue <h> --sat <s>) [--brightness <0-100>]
+  hue-control preset remove <name>`)
+}
+
+func runPresetList() {
+	listCmd := flag.NewFlagSet("preset list", flag.ExitOnError)
+	jsonOutput := listCmd.Bool("json", false, "Output presets as JSON")
+	listCmd.Parse(os.Args[3:])
+
+	user, err := loadUserPresets()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	presets := colorPresets()
+
+	if *jsonOutput {
+		out, _ := json.MarshalIndent(presets, "", "  ")
+		fmt.Println(string(out))
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tCOLOR\tSOURCE")
+	for _, name := range sortedIDs(presets) {
+		source := "built-in"
+		if _, ok := user[name]; ok {
+			source = "custom"
+			if _, builtin := builtinPresets[name]; builtin {
+				source = "custom (overrides built-in)"
+			}
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", name, presets[name].describe(), source)
+	}
+	w.Flush()
+}
+
+func runPresetAdd() {
+	addCmd := flag.NewFlagSet("preset add", flag.ExitOnError)
+	hex := addCmd.String("hex", "", "Color as hex, e.g. #ff8800")
+	xy := addCmd.String("xy", "", "Color as CIE x,y, e.g. 0.52,0.41")
+	ct := addCmd.Int("ct", 0, "Color temperature in mireds (153-500)")
+	hue := addCmd.Int("hue", -1, "Hue (0-65535), with --sat")
+	sat := addCmd.Int("sat", -1, "Saturation (0-254), with --hue")
+	brightness := addCmd.Int("brightness", -1, "Brightness percentage used with the preset (optional)")
+	args := parseInterspersed(addCmd, os.Args[3:])
+
+	if len(args) != 1 {
+		fmt.Println("Error: Usage: hue-control preset add <name> --hex <#rrggbb> [--brightness <0-100>]")
+		os.Exit(1)
+	}
+	name := strings.ToLower(strings.TrimSpace(args[0]))
+	if name == "" || strings.ContainsAny(name, ",. ") {
+		fmt.Println("Error: Preset names cannot be empty or contain spaces, commas or dots")
+		os.Exit(1)
+	}
+
+	p := Preset{Hex: *hex, CT: *ct}
+	if *xy != "" {
+		parts := strings.Split(*xy, ",")
+		for _, part := range parts {
+			v, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+			if err != nil {
+				fmt.Printf("Error: invalid xy '%s', expected e.g. 0.52,0.41\n", *xy)
+				os.Exit(1)
+			}
+			p.XY = append(p.XY, v)
+		}
+	}
+	if *hue >= 0 {
+		p.Hue = hue
+	}
+	if *sat >= 0 {
+		p.Sat = sat
+	}
+	if *brightness >= 0 {
+		p.Brightness = brightness
+	}
+	if err := p.validate(); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	user, err := loadUserPresets()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	_, replaced := user[name]
+	user[name] = p
+	if err := saveUserPresets(user); err != nil {
+		fmt.Printf("Error saving presets: %v\n", err)
+		os.Exit(1)
+	}
+
+	switch _, builtin := builtinPresets[name]; {
+	case replaced:
+		fmt.Printf("Updated preset '%s': %s\n", name, p.describe())
+	case builtin:
+		fmt.Printf("Added preset '%s': %s (overrides the built-in)\n", name, p.describe())
+	default:
+		fmt.Printf("Added preset '%s': %s\n", name, p.describe())
+	}
+}
+
+func runPresetRemove() {
+	if len(os.Args) != 4 {
+		fmt.Println("Error: Usage: hue-control preset remove <name>")
+		os.Exit(1)
+	}
+	name := strings.ToLower(strings.TrimSpace(os.Args[3]))
+
+	user, err := loadUserPresets()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	if _, ok := user[name]; !ok {
+		if _, builtin := builtinPresets[name]; builtin {
+			fmt.Printf("Error: '%s' is a built-in preset and cannot be removed (override it with 'preset add' instead)\n", name)
+		} else {
+			fmt.Printf("Error: preset '%s' not found\n", name)
+		}
+		os.Exit(1)
+	}
+
+	delete(user, name)
+	if err := saveUserPresets(user); err != nil {
+		fmt.Printf("Error saving presets: %v\n", err)
+		os.Exit(1)
+	}
+	if _, builtin := builtinPresets[name]; builtin {
+		fmt.Printf("Removed custom preset '%s'; the built-in is used again\n", name)
+	} else {
+		fmt.Printf("Removed preset '%s'\n", name)
+	}
+}
@@ -0,0 +1,354 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// roomClasses are the room classes the v1 API accepts for rooms and zones
+var roomClasses = []string{
+	"Living room", "Kitchen", "Dining", "Bedroom", "Kids bedroom", "Bathroom",
+	"Nursery", "Recreation", "Office", "Gym", "Hallway", "Toilet", "Front door",
+	"Garage", "Terrace", "Garden", "Driveway", "Carport", "Home", "Downstairs",
+	"Upstairs", "Top floor", "Attic", "Guest room", "Staircase", "Lounge",
+	"Man cave", "Computer", "Studio", "Music", "TV", "Reading", "Closet",
+	"Storage", "Laundry room", "Balcony", "Porch", "Barbecue", "Pool", "Free",
+	"Other",
+}
+
+func runRoom() {
+	if len(os.Args) < 3 {
+		printRoomUsage()
+		os.Exit(1)
+	}
+
+	switch os.Args[2] {
+	case "create":
+		runRoomCreate()
+	case "rename":
+		runRoomRename()
+	case "add-light":
+		runRoomMembership(true)
+	case "remove-light":
+		runRoomMembership(false)
+	case "delete":
+		runRoomDelete()
+	default:
+		fmt.Printf("Unknown room command: %s\n", os.Args[2])
+		printRoomUsage()
+		os.Exit(1)
+	}
+}
+
+func printRoomUsage() {
+	fmt.Println(`Usage:
+  hue-control room create <name> [--type Room|Zone] [--class <class>] [--lights <a,b,...>]
+  hue-control room rename <room> <new-name>
+  hue-control room add-light <room> <light> [<light>...]
+  hue-control room remove-light <room> <light> [<light>...]
+  hue-control room delete <room>`)
+}
+
+func runRoomCreate() {
+	createCmd := flag.NewFlagSet("room create", flag.ExitOnError)
+	groupType := createCmd.String("type", "Room", "Group type: Room or Zone")
+	class := createCmd.String("class", "Other", "Room class, e.g. Kitchen, Bedroom, Living room")
+	lightList := createCmd.String("lights", "", "Comma-separated light names or IDs")
+	args := parseInterspersed(createCmd, os.Args[3:])
+
+	if len(args) != 1 {
+		printRoomUsage()
+		os.Exit(1)
+	}
+	name := args[0]
+
+	var typeName string
+	switch strings.ToLower(*groupType) {
+	case "room":
+		typeName = "Room"
+	case "zone":
+		typeName = "Zone"
+	default:
+		fmt.Printf("Error: Unknown type '%s'. Use Room or Zone\n", *groupType)
+		os.Exit(1)
+	}
+
+	className, ok := findRoomClass(*class)
+	if !ok {
+		fmt.Printf("Error: Unknown class '%s'. Available: %s\n", *class, strings.Join(roomClasses, ", "))
+		os.Exit(1)
+	}
+
+	config, err := loadConfig()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	groups, err := getGroups(config)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	if err := checkGroupNameFree(groups, name, ""); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	lightIDs := []string{}
+	lights := map[string]Light{}
+	if *lightList != "" {
+		lights, err = getLights(config)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		lightIDs, err = resolveLights(lights, strings.Split(*lightList, ","))
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	if typeName == "Room" {
+		if err := checkLightsNotInRoom(groups, lights, lightIDs, ""); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	body := map[string]interface{}{
+		"name":   name,
+		"type":   typeName,
+		"class":  className,
+		"lights": lightIDs,
+	}
+	results, err := bridgeWrite(config, "POST", "/groups", body)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	id := ""
+	if len(results) > 0 {
+		id = fmt.Sprintf("%v", results[0]["id"])
+	}
+	fmt.Printf("Created %s '%s' (id %s, class %s) with %d lights\n", strings.ToLower(typeName), name, id, className, len(lightIDs))
+}
+
+func runRoomRename() {
+	if len(os.Args) != 5 {
+		printRoomUsage()
+		os.Exit(1)
+	}
+	roomName, newName := os.Args[3], os.Args[4]
+
+	config, err := loadConfig()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	groups, err := getGroups(config)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	groupID, ok := findGroup(groups, roomName)
+	if !ok {
+		fmt.Printf("Error: room '%s' not found. Use 'hue-control list' to see available rooms\n", roomName)
+		os.Exit(1)
+	}
+	if err := checkGroupNameFree(groups, newName, groupID); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if _, err := bridgeWrite(config, "PUT", "/groups/"+groupID, map[string]interface{}{"name": newName}); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Renamed '%s' to '%s'\n", groups[groupID].Name, newName)
+}
+
+// runRoomMembership adds lights to, or removes lights from, a room or zone
+func runRoomMembership(add bool) {
+	if len(os.Args) < 5 {
+		printRoomUsage()
+		os.Exit(1)
+	}
+	roomName := os.Args[3]
+
+	config, err := loadConfig()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	groups, err := getGroups(config)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	groupID, ok := findGroup(groups, roomName)
+	if !ok {
+		fmt.Printf("Error: room '%s' not found. Use 'hue-control list' to see available rooms\n", roomName)
+		os.Exit(1)
+	}
+	group := groups[groupID]
+
+	lights, err := getLights(config)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	lightIDs, err := resolveLights(lights, os.Args[4:])
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	members := map[string]bool{}
+	for _, id := range group.Lights {
+		members[id] = true
+	}
+
+	var changed []string
+	for _, id := range lightIDs {
+		if members[id] == add {
+			if add {
+				fmt.Printf("'%s' is already in '%s'\n", lights[id].Name, group.Name)
+			} else {
+				fmt.Printf("'%s' is not in '%s'\n", lights[id].Name, group.Name)
+			}
+			continue
+		}
+		members[id] = add
+		changed = append(changed, lights[id].Name)
+	}
+	if len(changed) == 0 {
+		return
+	}
+
+	if add && group.Type == "Room" {
+		if err := checkLightsNotInRoom(groups, lights, lightIDs, groupID); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	updated := []string{}
+	for _, id := range sortedIDs(members) {
+		if members[id] {
+			updated = append(updated, id)
+		}
+	}
+
+	if _, err := bridgeWrite(config, "PUT", "/groups/"+groupID, map[string]interface{}{"lights": updated}); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if add {
+		fmt.Printf("Added %s to '%s'\n", strings.Join(changed, ", "), group.Name)
+	} else {
+		fmt.Printf("Removed %s from '%s'\n", strings.Join(changed, ", "), group.Name)
+	}
+}
+
+func runRoomDelete() {
+	if len(os.Args) != 4 {
+		printRoomUsage()
+		os.Exit(1)
+	}
+	roomName := os.Args[3]
+
+	config, err := loadConfig()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	groups, err := getGroups(config)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	groupID, ok := findGroup(groups, roomName)
+	if !ok {
+		fmt.Printf("Error: room '%s' not found. Use 'hue-control list' to see available rooms\n", roomName)
+		os.Exit(1)
+	}
+
+	if _, err := bridgeWrite(config, "DELETE", "/groups/"+groupID, nil); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Deleted %s '%s' (id %s)\n", strings.ToLower(groups[groupID].Type), groups[groupID].Name, groupID)
+}
+
+// findRoomClass matches a room class case-insensitively and returns its canonical spelling
+func findRoomClass(class string) (string, bool) {
+	for _, c := range roomClasses {
+		if strings.EqualFold(c, class) {
+			return c, true
+		}
+	}
+	return "", false
+}
+
+// checkGroupNameFree returns an error if another group (other than exceptID) already uses name
+func checkGroupNameFree(groups map[string]Group, name, exceptID string) error {
+	if strings.TrimSpace(name) == "" {
+		return fmt.Errorf("room name must not be empty")
+	}
+	for _, id := range sortedIDs(groups) {
+		if id != exceptID && strings.EqualFold(groups[id].Name, name) {
+			return fmt.Errorf("a %s named '%s' already exists (id %s)", strings.ToLower(groups[id].Type), groups[id].Name, id)
+		}
+	}
+	return nil
+}
+
+// checkLightsNotInRoom returns an error if any light already belongs to a
+// room other than exceptID. A light can be in only one room, but in any number of zones.
+func checkLightsNotInRoom(groups map[string]Group, lights map[string]Light, lightIDs []string, exceptID string) error {
+	for _, gid := range sortedIDs(groups) {
+		group := groups[gid]
+		if gid == exceptID || group.Type != "Room" {
+			continue
+		}
+		for _, member := range group.Lights {
+			for _, id := range lightIDs {
+				if member == id {
+					return fmt.Errorf("light '%s' is already in room '%s'. Remove it there first with 'hue-control room remove-light'", lights[id].Name, group.Name)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// resolveLights resolves light names or IDs, failing on the first unknown one
+func resolveLights(lights map[string]Light, names []string) ([]string, error) {
+	ids := []string{}
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		id, ok := findLight(lights, name)
+		if !ok {
+			return nil, fmt.Errorf("light '%s' not found", name)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
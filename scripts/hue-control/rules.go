@@ -0,0 +1,780 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// The bridge rejects rules with more than this many conditions or actions
+const maxRuleConditions = 8
+const maxRuleActions = 8
+
+// Rule represents a rule stored on the Hue Bridge
+type Rule struct {
+	Name           string          `json:"name"`
+	Status         string          `json:"status,omitempty"`
+	LastTriggered  string          `json:"lasttriggered,omitempty"`
+	TimesTriggered int             `json:"timestriggered,omitempty"`
+	Conditions     []RuleCondition `json:"conditions"`
+	Actions        []RuleAction    `json:"actions"`
+}
+
+// RuleCondition is a single bridge rule condition, e.g. "/sensors/2/state/presence eq true"
+type RuleCondition struct {
+	Address  string `json:"address"`
+	Operator string `json:"operator"`
+	Value    string `json:"value,omitempty"`
+}
+
+// RuleAction is a single bridge rule action, a request the bridge sends to itself
+type RuleAction struct {
+	Address string                 `json:"address"`
+	Method  string                 `json:"method"`
+	Body    map[string]interface{} `json:"body"`
+}
+
+// ruleResources are the bridge resources a rule definition can refer to by name
+type ruleResources struct {
+	sensors map[string]Sensor
+	groups  map[string]Group
+	lights  map[string]Light
+}
+
+// sensorAttributes maps the attribute names accepted in rule definitions
+// to the sensor state attribute they read
+var sensorAttributes = map[string]string{
+	"presence":    "presence",
+	"temperature": "temperature",
+	"lightlevel":  "lightlevel",
+	"lux":         "lightlevel",
+	"dark":        "dark",
+	"daylight":    "daylight",
+	"buttonevent": "buttonevent",
+	"status":      "status",
+}
+
+func getRules(config *Config) (map[string]Rule, error) {
+	var rules map[string]Rule
+	if err := bridgeGet(config, "/rules", &rules); err != nil {
+		return nil, err
+	}
+
+	return rules, nil
+}
+
+func runRule() {
+	if len(os.Args) < 3 {
+		fmt.Println("Usage: hue-control rule list|create|delete")
+		os.Exit(1)
+	}
+
+	switch os.Args[2] {
+	case "list":
+		runRuleList()
+	case "create":
+		runRuleCreate()
+	case "delete":
+		runRuleDelete()
+	default:
+		fmt.Printf("Unknown rule command: %s\n", os.Args[2])
+		fmt.Println("Usage: hue-control rule list|create|delete")
+		os.Exit(1)
+	}
+}
+
+func runRuleList() {
+	config, err := loadConfig()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	res, err := loadRuleResources(config)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	rules, err := getRules(config)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Println("Bridge Rules:")
+	fmt.Println("------------------------")
+	for _, id := range sortedIDs(rules) {
+		rule := rules[id]
+		fmt.Printf("  [%s] %s (%s) - triggered %d times\n", id, rule.Name, rule.Status, rule.TimesTriggered)
+		for _, c := range rule.Conditions {
+			line := fmt.Sprintf("%s %s", res.describeAddress(c.Address), c.Operator)
+			if c.Value != "" {
+				line += " " + c.Value
+			}
+			fmt.Printf("      if   %s\n", line)
+		}
+		for _, a := range rule.Actions {
+			body, _ := json.Marshal(a.Body)
+			fmt.Printf("      then %s %s %s\n", a.Method, res.describeAddress(a.Address), body)
+		}
+	}
+}
+
+func runRuleCreate() {
+	createCmd := flag.NewFlagSet("rule create", flag.ExitOnError)
+	name := createCmd.String("name", "", "Rule name (overrides the name in the definition file)")
+	dryRun := createCmd.Bool("dry-run", false, "Print the compiled rule without uploading it")
+	createCmd.Parse(os.Args[3:])
+
+	if createCmd.NArg() != 1 {
+		fmt.Println("Usage: hue-control rule create [--name <name>] [--dry-run] <definition-file>")
+		os.Exit(1)
+	}
+
+	data, err := os.ReadFile(createCmd.Arg(0))
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	config, err := loadConfig()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	res, err := loadRuleResources(config)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	rule, err := compileRule(string(data), res)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	if *name != "" {
+		rule.Name = *name
+	}
+	if rule.Name == "" {
+		fmt.Println("Error: rule needs a name. Add a 'name: ...' line or use --name")
+		os.Exit(1)
+	}
+
+	if *dryRun {
+		out, _ := json.MarshalIndent(rule, "", "  ")
+		fmt.Println(string(out))
+		return
+	}
+
+	results, err := bridgeWrite(config, "POST", "/rules", rule)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	id := ""
+	if len(results) > 0 {
+		id = fmt.Sprintf("%v", results[0]["id"])
+	}
+	fmt.Printf("Created rule '%s' (id %s) with %d conditions and %d actions\n", rule.Name, id, len(rule.Conditions), len(rule.Actions))
+}
+
+func runRuleDelete() {
+	if len(os.Args) < 4 {
+		fmt.Println("Usage: hue-control rule delete <name|id>")
+		os.Exit(1)
+	}
+	target := os.Args[3]
+
+	config, err := loadConfig()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	rules, err := getRules(config)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	var ruleID string
+	for _, id := range sortedIDs(rules) {
+		if strings.EqualFold(rules[id].Name, target) {
+			ruleID = id
+			break
+		}
+	}
+	if _, ok := rules[target]; ruleID == "" && ok {
+		ruleID = target
+	}
+	if ruleID == "" {
+		fmt.Printf("Error: rule '%s' not found. Use 'hue-control rule list' to see available rules\n", target)
+		os.Exit(1)
+	}
+
+	if _, err := bridgeWrite(config, "DELETE", "/rules/"+ruleID, nil); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Deleted rule '%s' (id %s)\n", rules[ruleID].Name, ruleID)
+}
+
+func loadRuleResources(config *Config) (*ruleResources, error) {
+	sensors, err := getSensors(config)
+	if err != nil {
+		return nil, err
+	}
+	groups, err := getGroups(config)
+	if err != nil {
+		return nil, err
+	}
+	lights, err := getLights(config)
+	if err != nil {
+		return nil, err
+	}
+	return &ruleResources{sensors: sensors, groups: groups, lights: lights}, nil
+}
+
+// describeAddress replaces resource IDs in a rule address with their names,
+// e.g. "/sensors/2/state/presence" becomes "sensor 'Hall Motion' presence"
+func (res *ruleResources) describeAddress(address string) string {
+	parts := strings.Split(strings.TrimPrefix(address, "/"), "/")
+	if len(parts) < 2 {
+		return address
+	}
+	attr := strings.Join(parts[2:], "/")
+	switch parts[0] {
+	case "sensors":
+		if s, ok := res.sensors[parts[1]]; ok {
+			return strings.TrimSpace(fmt.Sprintf("sensor '%s' %s", s.Name, strings.TrimPrefix(attr, "state/")))
+		}
+	case "groups":
+		if g, ok := res.groups[parts[1]]; ok {
+			return strings.TrimSpace(fmt.Sprintf("room '%s' %s", g.Name, attr))
+		}
+	case "lights":
+		if l, ok := res.lights[parts[1]]; ok {
+			return strings.TrimSpace(fmt.Sprintf("light '%s' %s", l.Name, attr))
+		}
+	}
+	return address
+}
+
+// ruleToken is a word of a rule definition. Quoted names keep their spaces.
+type ruleToken struct {
+	text   string
+	quoted bool
+}
+
+// is reports whether the token is the given unquoted keyword
+func (t ruleToken) is(keyword string) bool {
+	return !t.quoted && strings.EqualFold(t.text, keyword)
+}
+
+// tokenizeRule splits a rule definition into words, keeping quoted strings
+// together and returning commas as separate tokens
+func tokenizeRule(text string) ([]ruleToken, error) {
+	var tokens []ruleToken
+	var current strings.Builder
+	flush := func() {
+		if current.Len() > 0 {
+			tokens = append(tokens, ruleToken{text: current.String()})
+			current.Reset()
+		}
+	}
+
+	runes := []rune(text)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case r == '"' || r == '\'':
+			flush()
+			end := i + 1
+			for end < len(runes) && runes[end] != r {
+				end++
+			}
+			if end == len(runes) {
+				return nil, fmt.Errorf("unterminated quote in rule definition")
+			}
+			tokens = append(tokens, ruleToken{text: string(runes[i+1 : end]), quoted: true})
+			i = end
+		case r == ',' || r == ';':
+			flush()
+			tokens = append(tokens, ruleToken{text: ","})
+		case r == ' ' || r == '\t' || r == '\n' || r == '\r':
+			flush()
+		default:
+			current.WriteRune(r)
+		}
+	}
+	flush()
+	return tokens, nil
+}
+
+// ruleParser compiles a tokenized rule definition into bridge conditions and actions
+type ruleParser struct {
+	tokens []ruleToken
+	pos    int
+	res    *ruleResources
+}
+
+func (p *ruleParser) peek() (ruleToken, bool) {
+	if p.pos >= len(p.tokens) {
+		return ruleToken{}, false
+	}
+	return p.tokens[p.pos], true
+}
+
+func (p *ruleParser) next() (ruleToken, error) {
+	t, ok := p.peek()
+	if !ok {
+		return ruleToken{}, fmt.Errorf("unexpected end of rule definition")
+	}
+	p.pos++
+	return t, nil
+}
+
+// accept consumes the next token if it is one of the given keywords
+func (p *ruleParser) accept(keywords ...string) (string, bool) {
+	t, ok := p.peek()
+	if !ok {
+		return "", false
+	}
+	for _, k := range keywords {
+		if t.is(k) {
+			p.pos++
+			return strings.ToLower(k), true
+		}
+	}
+	return "", false
+}
+
+func (p *ruleParser) expect(keywords ...string) (string, error) {
+	if k, ok := p.accept(keywords...); ok {
+		return k, nil
+	}
+	t, ok := p.peek()
+	if !ok {
+		return "", fmt.Errorf("expected '%s' but the definition ended", strings.Join(keywords, "' or '"))
+	}
+	return "", fmt.Errorf("expected '%s' but found '%s'", strings.Join(keywords, "' or '"), t.text)
+}
+
+// name reads a resource name: either a single quoted token, or unquoted
+// words up to (not including) one of the stop keywords
+func (p *ruleParser) name(stop func(ruleToken) bool) (string, error) {
+	t, ok := p.peek()
+	if !ok {
+		return "", fmt.Errorf("expected a name but the definition ended")
+	}
+	if t.quoted {
+		p.pos++
+		return t.text, nil
+	}
+
+	var words []string
+	for {
+		t, ok := p.peek()
+		if !ok || t.text == "," || stop(t) {
+			break
+		}
+		words = append(words, t.text)
+		p.pos++
+	}
+	if len(words) == 0 {
+		return "", fmt.Errorf("expected a name")
+	}
+	return strings.Join(words, " "), nil
+}
+
+// compileRule parses a rule definition such as
+//
+//	name: Hallway motion
+//	when sensor 'Hall Motion' presence is true and daylight is false,
+//	set room Hallway to 30% warm
+//
+// and resolves every sensor, room and light it mentions against the bridge.
+func compileRule(definition string, res *ruleResources) (*Rule, error) {
+	rule := &Rule{}
+
+	var body []string
+	for _, line := range strings.Split(definition, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.HasPrefix(strings.ToLower(line), "name:") {
+			rule.Name = strings.TrimSpace(line[len("name:"):])
+			continue
+		}
+		body = append(body, line)
+	}
+
+	tokens, err := tokenizeRule(strings.Join(body, " "))
+	if err != nil {
+		return nil, err
+	}
+	p := &ruleParser{tokens: tokens, res: res}
+
+	if _, err := p.expect("when", "if"); err != nil {
+		return nil, err
+	}
+
+	for {
+		conds, err := p.condition()
+		if err != nil {
+			return nil, err
+		}
+		rule.Conditions = append(rule.Conditions, conds...)
+
+		if _, ok := p.accept("and"); ok {
+			continue
+		}
+		break
+	}
+
+	// Fire on the sensor event itself rather than whenever the rule happens
+	// to be evaluated while its conditions hold
+	if !hasTrigger(rule.Conditions) {
+		for _, c := range rule.Conditions {
+			if strings.HasSuffix(c.Address, "/state/presence") || strings.HasSuffix(c.Address, "/state/buttonevent") {
+				rule.Conditions = append(rule.Conditions, RuleCondition{
+					Address:  path.Dir(c.Address) + "/lastupdated",
+					Operator: "dx",
+				})
+				break
+			}
+		}
+	}
+
+	if t, ok := p.peek(); ok && t.text == "," {
+		p.pos++
+	}
+	p.accept("then")
+
+	for {
+		action, err := p.action()
+		if err != nil {
+			return nil, err
+		}
+		rule.Actions = append(rule.Actions, action)
+
+		if t, ok := p.peek(); ok && t.text == "," {
+			p.pos++
+			p.accept("and", "then")
+			continue
+		}
+		if _, ok := p.accept("and", "then"); ok {
+			continue
+		}
+		break
+	}
+
+	if t, ok := p.peek(); ok {
+		return nil, fmt.Errorf("unexpected '%s' after the last action", t.text)
+	}
+	if len(rule.Conditions) > maxRuleConditions {
+		return nil, fmt.Errorf("rule has %d conditions, the bridge allows at most %d", len(rule.Conditions), maxRuleConditions)
+	}
+	if len(rule.Actions) > maxRuleActions {
+		return nil, fmt.Errorf("rule has %d actions, the bridge allows at most %d", len(rule.Actions), maxRuleActions)
+	}
+
+	return rule, nil
+}
+
+// hasTrigger reports whether any condition fires on a change
+func hasTrigger(conditions []RuleCondition) bool {
+	for _, c := range conditions {
+		if c.Operator == "dx" || c.Operator == "ddx" {
+			return true
+		}
+	}
+	return false
+}
+
+// condition parses "sensor <name> <attribute> <comparison>" or "daylight is <bool>"
+func (p *ruleParser) condition() ([]RuleCondition, error) {
+	if _, ok := p.accept("daylight"); ok {
+		id := ""
+		for _, sid := range sortedIDs(p.res.sensors) {
+			if p.res.sensors[sid].Type == "Daylight" {
+				id = sid
+				break
+			}
+		}
+		if id == "" {
+			return nil, fmt.Errorf("the bridge has no daylight sensor")
+		}
+		return p.comparison(fmt.Sprintf("/sensors/%s/state/daylight", id), "daylight")
+	}
+
+	if _, err := p.expect("sensor"); err != nil {
+		return nil, err
+	}
+	name, err := p.name(func(t ruleToken) bool {
+		_, ok := sensorAttributes[strings.ToLower(t.text)]
+		return ok
+	})
+	if err != nil {
+		return nil, err
+	}
+	t, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+	attr, ok := sensorAttributes[strings.ToLower(t.text)]
+	if !ok || t.quoted {
+		return nil, fmt.Errorf("unknown sensor attribute '%s'", t.text)
+	}
+
+	id, err := findSensorReading(p.res.sensors, name, attr)
+	if err != nil {
+		return nil, err
+	}
+
+	return p.comparison(fmt.Sprintf("/sensors/%s/state/%s", id, attr), strings.ToLower(t.text))
+}
+
+// comparison parses "is <value>", "is not <bool>", "is above|below <number>" or "changes"
+func (p *ruleParser) comparison(address, attr string) ([]RuleCondition, error) {
+	if _, ok := p.accept("changes", "changed"); ok {
+		return []RuleCondition{{Address: address, Operator: "dx"}}, nil
+	}
+
+	p.accept("is")
+	operator := "eq"
+	negate := false
+	if op, ok := p.accept("above", "over", "below", "under", "not"); ok {
+		switch op {
+		case "above", "over":
+			operator = "gt"
+		case "below", "under":
+			operator = "lt"
+		case "not":
+			negate = true
+		}
+	}
+
+	t, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+	value := strings.ToLower(t.text)
+
+	switch attr {
+	case "presence", "dark", "daylight":
+		if operator != "eq" {
+			return nil, fmt.Errorf("'%s' is true or false and cannot be compared with above/below", attr)
+		}
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("'%s' expects true or false, got '%s'", attr, t.text)
+		}
+		if negate {
+			b = !b
+		}
+		return []RuleCondition{{Address: address, Operator: "eq", Value: strconv.FormatBool(b)}}, nil
+	}
+
+	if negate {
+		return nil, fmt.Errorf("'is not' is only supported for true/false readings")
+	}
+
+	var bridgeValue int
+	switch attr {
+	case "temperature":
+		c, err := strconv.ParseFloat(strings.TrimRight(value, "°c"), 64)
+		if err != nil {
+			return nil, fmt.Errorf("temperature expects degrees Celsius, got '%s'", t.text)
+		}
+		bridgeValue = int(math.Round(c * 100))
+	case "lux":
+		lux, err := strconv.ParseFloat(strings.TrimSuffix(value, "lux"), 64)
+		if err != nil || lux <= 0 {
+			return nil, fmt.Errorf("lux expects a positive number, got '%s'", t.text)
+		}
+		bridgeValue = int(math.Round(10000*math.Log10(lux))) + 1
+	default:
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, fmt.Errorf("'%s' expects a number, got '%s'", attr, t.text)
+		}
+		bridgeValue = n
+	}
+
+	return []RuleCondition{{Address: address, Operator: operator, Value: strconv.Itoa(bridgeValue)}}, nil
+}
+
+// action parses "set room|light <name> to <spec>" or "turn room|light <name> on|off"
+func (p *ruleParser) action() (RuleAction, error) {
+	verb, err := p.expect("set", "turn")
+	if err != nil {
+		return RuleAction{}, err
+	}
+
+	// Allow "turn off room X" as well as "turn room X off"
+	leadingPower := ""
+	if verb == "turn" {
+		leadingPower, _ = p.accept("on", "off")
+	}
+
+	kind, err := p.expect("room", "group", "zone", "light")
+	if err != nil {
+		return RuleAction{}, err
+	}
+	name, err := p.name(func(t ruleToken) bool { return t.is("to") || t.is("on") || t.is("off") })
+	if err != nil {
+		return RuleAction{}, err
+	}
+
+	var address string
+	if kind == "light" {
+		id, ok := findLight(p.res.lights, name)
+		if !ok {
+			return RuleAction{}, fmt.Errorf("light '%s' not found", name)
+		}
+		address = fmt.Sprintf("/lights/%s/state", id)
+	} else {
+		id, ok := findGroup(p.res.groups, name)
+		if !ok {
+			return RuleAction{}, fmt.Errorf("room '%s' not found. Use 'hue-control list' to see available rooms", name)
+		}
+		address = fmt.Sprintf("/groups/%s/action", id)
+	}
+
+	body := map[string]interface{}{}
+	if verb == "turn" {
+		power := leadingPower
+		if power == "" {
+			if power, err = p.expect("on", "off"); err != nil {
+				return RuleAction{}, err
+			}
+		}
+		body["on"] = power == "on"
+		return RuleAction{Address: address, Method: "PUT", Body: body}, nil
+	}
+
+	if _, err := p.expect("to"); err != nil {
+		return RuleAction{}, err
+	}
+	if _, ok := p.accept("off"); ok {
+		body["on"] = false
+		return RuleAction{Address: address, Method: "PUT", Body: body}, nil
+	}
+
+	body["on"] = true
+	for {
+		t, ok := p.peek()
+		if !ok || t.text == "," || t.is("and") || t.is("then") {
+			break
+		}
+		p.pos++
+		word := strings.ToLower(t.text)
+		if m := percentPattern.FindStringSubmatch(word); m != nil {
+			pct, _ := strconv.Atoi(m[1])
+			if pct > 100 {
+				return RuleAction{}, fmt.Errorf("brightness must be between 0 and 100, got %d%%", pct)
+			}
+			body["bri"] = percentToBri(pct)
+			continue
+		}
+		preset, ok := colorPresets()[word]
+		if !ok {
+			return RuleAction{}, fmt.Errorf("unknown color '%s'. Available: %s", t.text, presetNames())
+		}
+		for key, value := range preset.state() {
+			body[key] = value
+		}
+		// An explicit percentage, before or after the color, wins
+		if _, ok := body["bri"]; !ok && preset.Brightness != nil {
+			body["bri"] = percentToBri(*preset.Brightness)
+		}
+	}
+	if len(body) == 1 {
+		return RuleAction{}, fmt.Errorf("'set %s %s to' needs a brightness, a color or 'off'", kind, name)
+	}
+
+	return RuleAction{Address: address, Method: "PUT", Body: body}, nil
+}
+
+var percentPattern = regexp.MustCompile(`^(\d+)%$`)
+
+// findSensor looks up a sensor by name (case-insensitive) or by ID
+func findSensor(sensors map[string]Sensor, nameOrID string) (string, bool) {
+	for _, id := range sortedIDs(sensors) {
+		if strings.EqualFold(sensors[id].Name, nameOrID) {
+			return id, true
+		}
+	}
+	if _, ok := sensors[nameOrID]; ok {
+		return nameOrID, true
+	}
+	return "", false
+}
+
+// findSensorReading resolves a sensor name to the sensor that provides attr.
+// A motion sensor shows up on the bridge as separate presence, temperature
+// and light level sensors, so "sensor 'Hall Motion' temperature" falls back
+// to the sibling sensor of the same device.
+func findSensorReading(sensors map[string]Sensor, nameOrID, attr string) (string, error) {
+	id, ok := findSensor(sensors, nameOrID)
+	if !ok {
+		return "", fmt.Errorf("sensor '%s' not found. Use 'hue-control sensors' to see available sensors", nameOrID)
+	}
+	if sensorHasAttribute(sensors[id], attr) {
+		return id, nil
+	}
+
+	if device := sensorDevice(sensors[id]); device != "" {
+		for _, sid := range sortedIDs(sensors) {
+			if sensorDevice(sensors[sid]) == device && sensorHasAttribute(sensors[sid], attr) {
+				return sid, nil
+			}
+		}
+	}
+
+	return "", fmt.Errorf("sensor '%s' (%s) has no '%s' reading", sensors[id].Name, sensors[id].Type, attr)
+}
+
+// sensorDevice returns the device part of a sensor's unique ID (the MAC
+// address before the endpoint suffix), shared by all sensors of one device
+func sensorDevice(sensor Sensor) string {
+	if i := strings.Index(sensor.UniqueID, "-"); i > 0 {
+		return sensor.UniqueID[:i]
+	}
+	return ""
+}
+
+// sensorHasAttribute reports whether a sensor exposes the given state attribute
+func sensorHasAttribute(sensor Sensor, attr string) bool {
+	s := sensor.State
+	switch attr {
+	case "presence":
+		return s.Presence != nil
+	case "temperature":
+		return s.Temperature != nil
+	case "lightlevel":
+		return s.LightLevel != nil
+	case "dark":
+		return s.Dark != nil
+	case "daylight":
+		return s.Daylight != nil
+	case "buttonevent":
+		return s.ButtonEvent != nil
+	case "status":
+		return s.Status != nil
+	}
+	return false
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func testRuleResources() *ruleResources {
+	yes, temp, level := true, 2150, 12000
+	return &ruleResources{
+		sensors: map[string]Sensor{
+			"1": {Name: "Daylight", Type: "Daylight", State: SensorState{Daylight: &yes}},
+			"2": {Name: "Hall Motion", Type: "ZLLPresence", UniqueID: "00:17:88:01:02:03:04:05-02-0406", State: SensorState{Presence: &yes}},
+			"3": {Name: "Hue temperature sensor 1", Type: "ZLLTemperature", UniqueID: "00:17:88:01:02:03:04:05-02-0402", State: SensorState{Temperature: &temp}},
+			"4": {Name: "Hue ambient light sensor 1", Type: "ZLLLightLevel", UniqueID: "00:17:88:01:02:03:04:05-02-0400", State: SensorState{LightLevel: &level, Dark: &yes}},
+		},
+		groups: map[string]Group{
+			"1": {Name: "Hallway", Type: "Room"},
+		},
+		lights: map[string]Light{
+			"5": {Name: "Porch"},
+		},
+	}
+}
+
+func TestCompileRule(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir()) // built-in presets only
+	res := testRuleResources()
+	motion := RuleCondition{Address: "/sensors/2/state/presence", Operator: "eq", Value: "true"}
+	motionEvent := RuleCondition{Address: "/sensors/2/state/lastupdated", Operator: "dx"}
+	hallOff := RuleAction{Address: "/groups/1/action", Method: "PUT", Body: map[string]interface{}{"on": false}}
+
+	tests := []struct {
+		definition string
+		want       Rule
+	}{
+		{"name: Hallway motion\nwhen sensor 'Hall Motion' presence is true, set room Hallway to off",
+			Rule{Name: "Hallway motion", Conditions: []RuleCondition{motion, motionEvent}, Actions: []RuleAction{hallOff}}},
+		{"# comment\nif sensor Hall Motion presence is not false then turn off room Hallway",
+			Rule{Conditions: []RuleCondition{motion, motionEvent}, Actions: []RuleAction{hallOff}}},
+		{"when sensor 'Hall Motion' presence changes, turn room Hallway off",
+			Rule{Conditions: []RuleCondition{{Address: "/sensors/2/state/presence", Operator: "dx"}}, Actions: []RuleAction{hallOff}}},
+		{"when daylight is false and sensor 'Hall Motion' presence is true, set room Hallway to 100% red",
+			Rule{
+				Conditions: []RuleCondition{{Address: "/sensors/1/state/daylight", Operator: "eq", Value: "false"}, motion, motionEvent},
+				Actions:    []RuleAction{{Address: "/groups/1/action", Method: "PUT", Body: map[string]interface{}{"on": true, "bri": 254, "hue": 0, "sat": 254}}},
+			}},
+		// The motion sensor's temperature and light level come from its sibling sensors
+		{"when sensor 'Hall Motion' temperature is above 21.5 and sensor 'Hall Motion' lux is below 10, turn light Porch on and turn room Hallway off",
+			Rule{
+				Conditions: []RuleCondition{
+					{Address: "/sensors/3/state/temperature", Operator: "gt", Value: "2150"},
+					{Address: "/sensors/4/state/lightlevel", Operator: "lt", Value: "10001"},
+				},
+				Actions: []RuleAction{{Address: "/lights/5/state", Method: "PUT", Body: map[string]interface{}{"on": true}}, hallOff},
+			}},
+	}
+	for _, tt := range tests {
+		got, err := compileRule(tt.definition, res)
+		if err != nil {
+			t.Errorf("compileRule(%q): %v", tt.definition, err)
+			continue
+		}
+		if !reflect.DeepEqual(*got, tt.want) {
+			t.Errorf("compileRule(%q) =\n%+v\nwant\n%+v", tt.definition, *got, tt.want)
+		}
+	}
+}
+
+func TestCompileRuleErrors(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	res := testRuleResources()
+
+	tests := []struct {
+		definition string
+		want       string // part of the error
+	}{
+		{"set room Hallway to off", "expected 'when' or 'if'"},
+		{"when sensor 'Attic' presence is true, turn room Hallway off", "sensor 'Attic' not found"},
+		{"when sensor 'Hall Motion' buttonevent is 1002, turn room Hallway off", "has no 'buttonevent' reading"},
+		{"when sensor 'Hall Motion' presence is above 1, turn room Hallway off", "cannot be compared"},
+		{"when sensor 'Hall Motion' presence is maybe, turn room Hallway off", "expects true or false"},
+		{"when sensor 'Hall Motion' presence is true, turn room Attic off", "room 'Attic' not found"},
+		{"when sensor 'Hall Motion' presence is true, set room Hallway to", "needs a brightness"},
+		{"when sensor 'Hall Motion' presence is true, set room Hallway to 150%", "between 0 and 100"},
+		{"when sensor 'Hall Motion' presence is true, set room Hallway to mauve", "unknown color 'mauve'"},
+		{"when sensor 'Hall Motion' presence is true, turn room Hallway off please", "after the last action"},
+		{"when sensor 'Hall Motion presence is true", "unterminated quote"},
+	}
+	for _, tt := range tests {
+		_, err := compileRule(tt.definition, res)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("compileRule(%q) error = %v, want it to contain %q", tt.definition, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,572 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// sayCommand is what 'say' understood from a phrase
+type sayCommand struct {
+	Room       string // group name, or "all"
+	GroupID    string // "" for all lights
+	Power      string // "on", "off" or ""
+	Brightness int    // absolute percent, -1 if not given
+	Relative   int    // change in percent for brighter/dimmer, 0 if not given
+	Color      string // preset name or hex color
+	Scene      string // scene name
+}
+
+// describe returns e.g. "Living Room: brightness 30%, color warm"
+func (c sayCommand) describe() string {
+	var parts []string
+	switch {
+	case c.Scene != "":
+		parts = append(parts, fmt.Sprintf("recall scene '%s'", c.Scene))
+	case c.Power == "off":
+		parts = append(parts, "turn off")
+	case c.Power == "on" && c.Brightness < 0 && c.Relative == 0 && c.Color == "":
+		parts = append(parts, "turn on")
+	}
+	if c.Brightness >= 0 {
+		parts = append(parts, fmt.Sprintf("brightness %d%%", c.Brightness))
+	}
+	if c.Relative > 0 {
+		parts = append(parts, fmt.Sprintf("%d%% brighter", c.Relative))
+	} else if c.Relative < 0 {
+		parts = append(parts, fmt.Sprintf("%d%% dimmer", -c.Relative))
+	}
+	if c.Color != "" {
+		parts = append(parts, "color "+c.Color)
+	}
+	room := c.Room
+	if room == "all" {
+		room = "All lights"
+	}
+	return room + ": " + strings.Join(parts, ", ")
+}
+
+// sayFillers are words that carry no meaning for the parser
+var sayFillers = map[string]bool{
+	"the": true, "a": true, "an": true, "to": true, "in": true, "of": true, "at": true, "and": true,
+	"please": true, "can": true, "could": true, "would": true, "you": true, "me": true, "my": true,
+	"it": true, "them": true, "light": true, "lights": true, "lamp": true, "lamps": true,
+	"set": true, "make": true, "turn": true, "switch": true, "put": true, "change": true, "shut": true,
+	"with": true, "bit": true, "little": true, "slightly": true, "much": true, "lot": true, "more": true,
+	"percent": true, "pct": true, "%": true, "by": true, "color": true, "colour": true, "now": true,
+	"brightness": true, "level": true, "room": true, "is": true, "be": true, "for": true, "up": true, "down": true,
+	"touch": true, "way": true,
+}
+
+// sayAllWords mean every light
+var sayAllWords = map[string]bool{"all": true, "everything": true, "everywhere": true, "house": true, "home": true, "whole": true}
+
+// sayColorWords map common color words to presets
+var sayColorWords = map[string]string{
+	"warmer": "warm", "cozy": "warm", "cosy": "warm",
+	"cooler": "cool", "cold": "cool", "colder": "cool", "daylight": "cool",
+	"violet": "purple", "amber": "orange", "magenta": "pink", "turquoise": "cyan",
+}
+
+var sayNumberPattern = regexp.MustCompile(`^\d{1,3}$`)
+
+// parseSay turns a phrase such as "dim the living room to 30 percent and
+// make it warm" into a command. It returns an error for phrases it can't
+// understand or that could mean more than one thing.
+func parseSay(text string, groups map[string]Group, scenes map[string]Scene) (sayCommand, error) {
+	cmd := sayCommand{Brightness: -1}
+	words := sayTokens(text)
+	if len(words) == 0 {
+		return cmd, fmt.Errorf("nothing to do")
+	}
+	used := make([]bool, len(words))
+
+	// Room first, so room names don't get read as colors ("Red Room")
+	var rooms []string
+	for _, id := range sortedIDs(groups) {
+		rooms = append(rooms, groups[id].Name)
+	}
+	room, err := sayMatch(words, used, rooms, "room")
+	if err != nil {
+		return cmd, err
+	}
+	all := false
+	for i, w := range words {
+		if !used[i] && sayAllWords[w] {
+			all = true
+			used[i] = true
+		}
+	}
+	switch {
+	case room != "" && all:
+		return cmd, fmt.Errorf("ambiguous: both '%s' and all lights were mentioned", room)
+	case room != "":
+		cmd.Room = room
+		cmd.GroupID, _ = findGroup(groups, room)
+	case all:
+		cmd.Room = "all"
+	default:
+		return cmd, fmt.Errorf("no room given. Name a room (%s) or say 'all lights'", strings.Join(rooms, ", "))
+	}
+
+	// Brightness: "30 percent", "30%", "to 30", "half", "full"; "by 10" is a step
+	number := -1
+	by := false
+	for i, w := range words {
+		value := -1
+		switch {
+		case sayNumberPattern.MatchString(w):
+			value, _ = strconv.Atoi(w)
+		case w == "half":
+			value = 50
+		case w == "full" || w == "max" || w == "maximum":
+			value = 100
+		case w == "min" || w == "minimum" || w == "lowest":
+			value = 1
+		}
+		if value < 0 || used[i] {
+			continue
+		}
+		if number >= 0 && number != value {
+			return cmd, fmt.Errorf("ambiguous: more than one brightness (%d and %d)", number, value)
+		}
+		if value > 100 {
+			return cmd, fmt.Errorf("brightness %d is more than 100 percent", value)
+		}
+		number = value
+		by = i > 0 && words[i-1] == "by"
+		used[i] = true
+	}
+
+	// Power and relative changes
+	step := 20
+	for i, w := range words {
+		switch w {
+		case "bit", "little", "slightly", "touch":
+			step = 10
+		case "much", "lot", "way":
+			step = 30
+		}
+		direction := 0
+		switch w {
+		case "on":
+			cmd.Power = sayPower(cmd.Power, "on")
+		case "off", "out":
+			cmd.Power = sayPower(cmd.Power, "off")
+		case "brighter", "brighten", "raise", "increase", "up":
+			direction = 1
+		case "dim", "dimmer", "darker", "darken", "lower", "decrease", "down":
+			direction = -1
+		default:
+			continue
+		}
+		used[i] = true
+		if direction != 0 {
+			if cmd.Relative != 0 && (cmd.Relative > 0) != (direction > 0) {
+				return cmd, fmt.Errorf("ambiguous: both brighter and dimmer")
+			}
+			cmd.Relative = direction
+		}
+	}
+	if cmd.Power == "conflict" {
+		return cmd, fmt.Errorf("ambiguous: both on and off")
+	}
+	switch {
+	case number >= 0 && by:
+		if cmd.Relative == 0 {
+			return cmd, fmt.Errorf("'by %d' needs brighter or dimmer", number)
+		}
+		cmd.Relative *= number
+	case number >= 0:
+		// "dim to 30" is absolute
+		cmd.Brightness = number
+		cmd.Relative = 0
+	default:
+		cmd.Relative *= step
+	}
+
+	// Scenes only when asked for, as scene names often look like colors
+	asked := false
+	for i, w := range words {
+		if w == "scene" || w == "recall" || w == "activate" {
+			asked = true
+			used[i] = true
+		}
+	}
+	if asked {
+		if cmd.GroupID == "" {
+			return cmd, fmt.Errorf("scenes belong to a room; name the room")
+		}
+		var names []string
+		for _, id := range sortedIDs(scenes) {
+			if scenes[id].Group == cmd.GroupID {
+				names = append(names, scenes[id].Name)
+			}
+		}
+		if cmd.Scene, err = sayMatch(words, used, names, "scene"); err != nil {
+			return cmd, err
+		}
+		if cmd.Scene == "" {
+			return cmd, fmt.Errorf("no scene named in '%s'. %s has: %s", text, cmd.Room, strings.Join(names, ", "))
+		}
+	}
+
+	// Colors: hex, color words and preset names (also fuzzy)
+	var colors []string
+	for i, w := range words {
+		if used[i] {
+			continue
+		}
+		if strings.HasPrefix(w, "#") {
+			if _, err := parseHexColor(w); err != nil {
+				return cmd, err
+			}
+			colors = append(colors, w)
+			used[i] = true
+		} else if preset, ok := sayColorWords[w]; ok {
+			colors = append(colors, preset)
+			used[i] = true
+		}
+	}
+	var presets []string
+	for _, name := range sortedIDs(colorPresets()) {
+		presets = append(presets, strings.ReplaceAll(name, "-", " "))
+	}
+	// Keep filler words from being read as misspelled colors ("mellow")
+	colorUsed := make([]bool, len(words))
+	for i, w := range words {
+		colorUsed[i] = used[i] || sayFillers[w]
+	}
+	for {
+		color, err := sayMatch(words, colorUsed, presets, "color")
+		if err != nil {
+			return cmd, err
+		}
+		if color == "" {
+			break
+		}
+		colors = append(colors, strings.ReplaceAll(color, " ", "-"))
+	}
+	for i := range words {
+		used[i] = used[i] || (colorUsed[i] && !sayFillers[words[i]])
+	}
+	for _, c := range colors {
+		if cmd.Color != "" && c != cmd.Color {
+			return cmd, fmt.Errorf("ambiguous: more than one color (%s and %s)", cmd.Color, c)
+		}
+		cmd.Color = c
+	}
+
+	var unknown []string
+	for i, w := range words {
+		if !used[i] && !sayFillers[w] {
+			unknown = append(unknown, w)
+		}
+	}
+	switch {
+	case cmd.Scene != "" && (cmd.Color != "" || cmd.Brightness >= 0 || cmd.Relative != 0 || cmd.Power == "off"):
+		return cmd, fmt.Errorf("ambiguous: a scene can't be combined with other changes")
+	case cmd.Power == "off" && (cmd.Color != "" || cmd.Brightness > 0 || cmd.Relative != 0):
+		return cmd, fmt.Errorf("ambiguous: turning off conflicts with the other changes")
+	case cmd.Power == "" && cmd.Scene == "" && cmd.Color == "" && cmd.Brightness < 0 && cmd.Relative == 0:
+		if len(unknown) > 0 {
+			return cmd, fmt.Errorf("didn't understand '%s'", strings.Join(unknown, " "))
+		}
+		return cmd, fmt.Errorf("no change given for %s", cmd.Room)
+	case len(unknown) > 0:
+		return cmd, fmt.Errorf("didn't understand '%s' (understood so far: %s)", strings.Join(unknown, " "), cmd.describe())
+	}
+	if cmd.Brightness == 0 {
+		cmd.Brightness = -1
+		cmd.Power = "off"
+		if cmd.Color != "" {
+			return cmd, fmt.Errorf("ambiguous: 0 percent turns the lights off, but a color was given")
+		}
+	}
+	return cmd, nil
+}
+
+// sayPower records an on or off, remembering a contradiction
+func sayPower(current, power string) string {
+	if current != "" && current != power {
+		return "conflict"
+	}
+	return power
+}
+
+// sayTokens lowercases the text and splits it into words, keeping hex
+// colors and splitting "30%" into "30" and "%"
+func sayTokens(text string) []string {
+	text = strings.ToLower(text)
+	text = strings.ReplaceAll(text, "%", " % ")
+	text = strings.ReplaceAll(text, "per cent", "percent")
+	var words []string
+	for _, w := range strings.FieldsFunc(text, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '#' || r == '%' || r == '\'')
+	}) {
+		w = strings.Trim(w, "'")
+		if w != "" {
+			words = append(words, w)
+		}
+	}
+	return words
+}
+
+// sayMatch finds the one name (room, scene or color) mentioned in the
+// unused words, allowing small typos, and marks its words used. Longer
+// matches win, then full names over shortened ones, then fewer typos; ""
+// means no match. Two different names that tie are ambiguous.
+func sayMatch(words []string, used []bool, names []string, what string) (string, error) {
+	type match struct {
+		name       string
+		start, end int
+		full       bool
+		typos      int
+	}
+	// compare returns >0 if a is the better match, <0 if b is
+	compare := func(a, b match) int {
+		if a.end-a.start != b.end-b.start {
+			return (a.end - a.start) - (b.end - b.start)
+		}
+		if a.full != b.full {
+			if a.full {
+				return 1
+			}
+			return -1
+		}
+		return b.typos - a.typos
+	}
+	var best []match
+	for _, name := range names {
+		for k, alias := range sayAliases(name) {
+			n := len(alias)
+			for start := 0; start+n <= len(words); start++ {
+				free := true
+				for i := start; i < start+n; i++ {
+					free = free && !used[i]
+				}
+				if !free {
+					continue
+				}
+				phrase := strings.Join(words[start:start+n], " ")
+				target := strings.Join(alias, " ")
+				d := editDistance(phrase, target)
+				// Typos are only allowed after a correct first letter, so
+				// "mellow" isn't taken for "yellow"
+				if d > sayTypos(target) || (d > 0 && phrase[0] != target[0]) {
+					continue
+				}
+				m := match{name, start, start + n, k == 0, d}
+				switch {
+				case len(best) == 0 || compare(m, best[0]) > 0:
+					best = []match{m}
+				case compare(m, best[0]) == 0:
+					best = append(best, m)
+				}
+			}
+		}
+	}
+	if len(best) == 0 {
+		return "", nil
+	}
+	for _, m := range best[1:] {
+		if !strings.EqualFold(m.name, best[0].name) {
+			var names []string
+			for _, m := range best {
+				names = append(names, "'"+m.name+"'")
+			}
+			return "", fmt.Errorf("ambiguous %s: could be %s", what, strings.Join(names, " or "))
+		}
+	}
+	for i := best[0].start; i < best[0].end; i++ {
+		used[i] = true
+	}
+	return best[0].name, nil
+}
+
+// sayAliases returns the ways a name can be said: in full, and without
+// generic words, so "Living Room" also matches "the living"
+func sayAliases(name string) [][]string {
+	full := sayTokens(name)
+	aliases := [][]string{full}
+	var short []string
+	for _, w := range full {
+		if w != "room" && w != "zone" && w != "lights" && w != "the" {
+			short = append(short, w)
+		}
+	}
+	if len(short) > 0 && len(short) < len(full) {
+		aliases = append(aliases, short)
+	}
+	return aliases
+}
+
+// sayTypos is how many typos a name of this length may have
+func sayTypos(name string) int {
+	switch {
+	case len(name) <= 4:
+		return 0
+	case len(name) <= 8:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// editDistance counts the insertions, deletions, substitutions and swaps
+// of neighboring letters that turn a into b
+func editDistance(a, b string) int {
+	d := make([][]int, len(a)+1)
+	for i := range d {
+		d[i] = make([]int, len(b)+1)
+		d[i][0] = i
+	}
+	for j := range d[0] {
+		d[0][j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			d[i][j] = min(d[i-1][j]+1, d[i][j-1]+1, d[i-1][j-1]+cost)
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] {
+				d[i][j] = min(d[i][j], d[i-2][j-2]+1)
+			}
+		}
+	}
+	return d[len(a)][len(b)]
+}
+
+func runSay() {
+	sayCmd := flag.NewFlagSet("say", flag.ExitOnError)
+	dryRun := sayCmd.Bool("dry-run", false, "Only show what was understood")
+	sayCmd.Parse(os.Args[2:])
+
+	text := strings.Join(sayCmd.Args(), " ")
+	if strings.TrimSpace(text) == "" {
+		fmt.Println(`Error: Say what to do, e.g. hue-control say "dim the living room to 30 percent and make it warm"`)
+		os.Exit(1)
+	}
+
+	config, err := loadConfig()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	groups, err := getGroups(config)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	scenes, err := getScenes(config)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	cmd, err := parseSay(text, groups, scenes)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Understood: %s\n", cmd.describe())
+	if *dryRun {
+		return
+	}
+
+	notes, err := runSayCommand(config, cmd)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	printNotes(notes)
+}
+
+// runSayCommand carries out a parsed command with the same operations as
+// set, on, off and scene recall
+func runSayCommand(config *Config, cmd sayCommand) ([]string, error) {
+	switch {
+	case cmd.Scene != "":
+		return nil, recallScene(config, cmd.Room, cmd.Scene)
+	case cmd.Power != "" && cmd.Brightness < 0 && cmd.Relative == 0 && cmd.Color == "":
+		if cmd.Room == "all" {
+			return nil, setAllLights(config, cmd.Power == "on", 0, nil)
+		}
+		return nil, setRoomPower(config, cmd.Room, cmd.Power == "on")
+	}
+
+	var color map[string]interface{}
+	brightness := cmd.Brightness
+	if cmd.Color != "" {
+		var err error
+		if color, err = colorState(cmd.Color); err != nil {
+			return nil, err
+		}
+		if preset, ok := colorPresets()[cmd.Color]; ok && preset.Brightness != nil && brightness < 0 && cmd.Relative == 0 {
+			brightness = *preset.Brightness
+		}
+	}
+	if brightness < 0 {
+		// Relative changes and color-only changes start from the current
+		// brightness, or the one the lights had when turned off
+		current, on, err := currentRoomBrightness(config, cmd.GroupID)
+		if err != nil {
+			return nil, err
+		}
+		if !on && cmd.Relative != 0 && cmd.Power != "on" {
+			// Dimming a dark room shouldn't turn it on
+			what := cmd.Room + " is"
+			if cmd.Room == "all" {
+				what = "All lights are"
+			}
+			return nil, fmt.Errorf("%s off. Turn it on first, or give a brightness such as 50%%", what)
+		}
+		brightness = max(1, min(100, current+cmd.Relative))
+	}
+	return setRoomState(config, cmd.Room, percentToBri(brightness), color)
+}
+
+// currentRoomBrightness returns the average brightness in percent of the
+// lights that are on in a group ("" for all lights), and whether any are
+// on. If all are off, it returns the brightness they had when turned off.
+func currentRoomBrightness(config *Config, groupID string) (int, bool, error) {
+	lights, err := getLights(config)
+	if err != nil {
+		return 0, false, err
+	}
+	ids := sortedIDs(lights)
+	if groupID != "" {
+		groups, err := getGroups(config)
+		if err != nil {
+			return 0, false, err
+		}
+		ids = groups[groupID].Lights
+	}
+	var onTotal, onCount, offTotal, offCount int
+	for _, id := range ids {
+		l, ok := lights[id]
+		if !ok || !l.State.Reachable || !l.dimmable() {
+			continue
+		}
+		if l.State.On {
+			onTotal += briToPercent(l.State.Bri)
+			onCount++
+		} else {
+			offTotal += briToPercent(l.State.Bri)
+			offCount++
+		}
+	}
+	switch {
+	case onCount > 0:
+		return onTotal / onCount, true, nil
+	case offCount > 0:
+		return offTotal / offCount, false, nil
+	}
+	// No dimmable lights, so the brightness makes no difference
+	return 100, false, nil
+}
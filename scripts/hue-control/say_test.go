@@ -0,0 +1,93 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+var sayTestGroups = map[string]Group{
+	"1": {Name: "Living Room", Type: "Room"},
+	"2": {Name: "Kitchen", Type: "Room"},
+	"3": {Name: "Office", Type: "Room"},
+	"4": {Name: "Red Room", Type: "Room"},
+}
+
+var sayTestScenes = map[string]Scene{
+	"abc": {Name: "Relax", Type: "GroupScene", Group: "1"},
+}
+
+func TestParseSay(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir()) // built-in presets only
+
+	tests := []struct {
+		text string
+		want sayCommand
+	}{
+		{"dim the living room to 30 percent and make it warm",
+			sayCommand{Room: "Living Room", GroupID: "1", Brightness: 30, Color: "warm"}},
+		{"kitchen 50%", sayCommand{Room: "Kitchen", GroupID: "2", Brightness: 50}},
+		{"turn off the office", sayCommand{Room: "Office", GroupID: "3", Power: "off", Brightness: -1}},
+		{"office to 0", sayCommand{Room: "Office", GroupID: "3", Power: "off", Brightness: -1}},
+		{"all lights on", sayCommand{Room: "all", Power: "on", Brightness: -1}},
+		{"make the office a bit dimmer", sayCommand{Room: "Office", GroupID: "3", Brightness: -1, Relative: -10}},
+		{"kitchen a lot brighter", sayCommand{Room: "Kitchen", GroupID: "2", Brightness: -1, Relative: 30}},
+		{"living brighter by 15", sayCommand{Room: "Living Room", GroupID: "1", Brightness: -1, Relative: 15}},
+		{"office half", sayCommand{Room: "Office", GroupID: "3", Brightness: 50}},
+		{"offfice blue", sayCommand{Room: "Office", GroupID: "3", Brightness: -1, Color: "blue"}},
+		{"office purpel", sayCommand{Room: "Office", GroupID: "3", Brightness: -1, Color: "purple"}},
+		{"kitchen #ff8800", sayCommand{Room: "Kitchen", GroupID: "2", Brightness: -1, Color: "#ff8800"}},
+		{"red room cozy", sayCommand{Room: "Red Room", GroupID: "4", Brightness: -1, Color: "warm"}},
+		{"recall relax scene in the living room", sayCommand{Room: "Living Room", GroupID: "1", Brightness: -1, Scene: "Relax"}},
+	}
+	for _, tt := range tests {
+		got, err := parseSay(tt.text, sayTestGroups, sayTestScenes)
+		if err != nil {
+			t.Errorf("parseSay(%q): %v", tt.text, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("parseSay(%q) = %+v, want %+v", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestParseSayErrors(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	tests := []struct {
+		text string
+		want string // part of the error
+	}{
+		{"", "nothing to do"},
+		{"make it blue", "no room given"},
+		{"kitchen and all lights off", "ambiguous"},
+		{"office off and blue", "turning off conflicts"},
+		{"office mellow", "didn't understand 'mellow'"},
+		{"office", "no change given"},
+		{"recall relax scene in the living room at 50%", "a scene can't be combined"},
+	}
+	for _, tt := range tests {
+		_, err := parseSay(tt.text, sayTestGroups, sayTestScenes)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("parseSay(%q) error = %v, want it to contain %q", tt.text, err, tt.want)
+		}
+	}
+}
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"office", "office", 0},
+		{"offfice", "office", 1},
+		{"purpel", "purple", 1}, // a swap of neighboring letters is one edit
+		{"kitchen", "kitten", 2},
+		{"", "red", 3},
+	}
+	for _, tt := range tests {
+		if got := editDistance(tt.a, tt.b); got != tt.want {
+			t.Errorf("editDistance(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
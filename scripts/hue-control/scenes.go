@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Scene represents a Hue scene stored on the bridge
+type Scene struct {
+	Name   string   `json:"name"`
+	Type   string   `json:"type"`  // GroupScene or LightScene
+	Group  string   `json:"group"` // for GroupScene
+	Lights []string `json:"lights"`
+}
+
+func getScenes(config *Config) (map[string]Scene, error) {
+	var scenes map[string]Scene
+	if err := bridgeGet(config, "/scenes", &scenes); err != nil {
+		return nil, err
+	}
+	return scenes, nil
+}
+
+// findScene looks up a scene by name (case-insensitive) or ID. With a
+// groupID only scenes of that room or zone match, since each room usually
+// has its own scene with the same name (e.g. "Relax").
+func findScene(scenes map[string]Scene, nameOrID, groupID string) (string, bool) {
+	if scene, ok := scenes[nameOrID]; ok && (groupID == "" || scene.Group == groupID) {
+		return nameOrID, true
+	}
+	for _, id := range sortedIDs(scenes) {
+		scene := scenes[id]
+		if strings.EqualFold(scene.Name, nameOrID) && (groupID == "" || scene.Group == groupID) {
+			return id, true
+		}
+	}
+	return "", false
+}
+
+// recallScene activates a room's scene by name or ID
+func recallScene(config *Config, roomName, sceneName string) error {
+	groups, err := getGroups(config)
+	if err != nil {
+		return err
+	}
+	groupID, ok := findGroup(groups, roomName)
+	if !ok {
+		return fmt.Errorf("room '%s' not found. Use 'hue-control list' to see available rooms", roomName)
+	}
+
+	scenes, err := getScenes(config)
+	if err != nil {
+		return err
+	}
+	sceneID, ok := findScene(scenes, sceneName, groupID)
+	if !ok {
+		var names []string
+		for _, id := range sortedIDs(scenes) {
+			if scenes[id].Group == groupID {
+				names = append(names, scenes[id].Name)
+			}
+		}
+		return fmt.Errorf("scene '%s' not found in %s. Available: %s", sceneName, groups[groupID].Name, strings.Join(names, ", "))
+	}
+
+	_, err = bridgeWrite(config, "PUT", "/groups/"+groupID+"/action", map[string]interface{}{"scene": sceneID})
+	return err
+}
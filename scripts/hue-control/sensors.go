@@ -0,0 +1,200 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+	"time"
+)
+
+// Sensor represents a Hue sensor (motion, temperature, light level, switch, ...)
+type Sensor struct {
+	Name             string       `json:"name"`
+	Type             string       `json:"type"`
+	ModelID          string       `json:"modelid"`
+	ManufacturerName string       `json:"manufacturername"`
+	UniqueID         string       `json:"uniqueid"`
+	State            SensorState  `json:"state"`
+	Config           SensorConfig `json:"config"`
+}
+
+// SensorState holds the readings of a sensor. Only the fields relevant
+// to the sensor type are present, so they are all optional.
+type SensorState struct {
+	Presence    *bool  `json:"presence,omitempty"`
+	Temperature *int   `json:"temperature,omitempty"` // hundredths of a degree Celsius
+	LightLevel  *int   `json:"lightlevel,omitempty"`  // 10000*log10(lux)+1
+	Dark        *bool  `json:"dark,omitempty"`
+	Daylight    *bool  `json:"daylight,omitempty"`
+	ButtonEvent *int   `json:"buttonevent,omitempty"`
+	Status      *int   `json:"status,omitempty"`
+	LastUpdated string `json:"lastupdated"`
+}
+
+// SensorConfig holds the configuration attributes of a sensor
+type SensorConfig struct {
+	On        bool  `json:"on"`
+	Battery   *int  `json:"battery,omitempty"`
+	Reachable *bool `json:"reachable,omitempty"`
+}
+
+// SensorReading is the flattened, script-friendly view of a sensor used for JSON output
+type SensorReading struct {
+	ID           string   `json:"id"`
+	Name         string   `json:"name"`
+	Type         string   `json:"type"`
+	Model        string   `json:"model,omitempty"`
+	Battery      *int     `json:"battery,omitempty"`
+	Reachable    *bool    `json:"reachable,omitempty"`
+	LastUpdated  string   `json:"last_updated,omitempty"`
+	Presence     *bool    `json:"presence,omitempty"`
+	TemperatureC *float64 `json:"temperature_c,omitempty"`
+	Lux          *float64 `json:"lux,omitempty"`
+	Dark         *bool    `json:"dark,omitempty"`
+	Daylight     *bool    `json:"daylight,omitempty"`
+	ButtonEvent  *int     `json:"button_event,omitempty"`
+	Status       *int     `json:"status,omitempty"`
+}
+
+func getSensors(config *Config) (map[string]Sensor, error) {
+	var sensors map[string]Sensor
+	if err := bridgeGet(config, "/sensors", &sensors); err != nil {
+		return nil, err
+	}
+
+	return sensors, nil
+}
+
+// temperatureCelsius converts the bridge's hundredths of a degree to °C
+func (s SensorState) temperatureCelsius() *float64 {
+	if s.Temperature == nil {
+		return nil
+	}
+	c := float64(*s.Temperature) / 100.0
+	return &c
+}
+
+// lux converts the bridge's logarithmic light level to lux
+func (s SensorState) lux() *float64 {
+	if s.LightLevel == nil {
+		return nil
+	}
+	lux := math.Pow(10, float64(*s.LightLevel-1)/10000.0)
+	lux = math.Round(lux*10) / 10
+	return &lux
+}
+
+// lastUpdated parses the bridge timestamp (UTC, no zone) into a time.
+// The bridge reports "none" for sensors that never sent a reading.
+func (s SensorState) lastUpdated() (time.Time, bool) {
+	t, err := time.ParseInLocation("2006-01-02T15:04:05", s.LastUpdated, time.UTC)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return t, true
+}
+
+func newSensorReading(id string, sensor Sensor) SensorReading {
+	reading := SensorReading{
+		ID:           id,
+		Name:         sensor.Name,
+		Type:         sensor.Type,
+		Model:        sensor.ModelID,
+		Battery:      sensor.Config.Battery,
+		Reachable:    sensor.Config.Reachable,
+		Presence:     sensor.State.Presence,
+		TemperatureC: sensor.State.temperatureCelsius(),
+		Lux:          sensor.State.lux(),
+		Dark:         sensor.State.Dark,
+		Daylight:     sensor.State.Daylight,
+		ButtonEvent:  sensor.State.ButtonEvent,
+		Status:       sensor.State.Status,
+	}
+	if t, ok := sensor.State.lastUpdated(); ok {
+		reading.LastUpdated = t.Format(time.RFC3339)
+	}
+	return reading
+}
+
+// describe returns the human readable reading of a sensor, e.g. "presence: yes"
+func (r SensorReading) describe() string {
+	var parts []string
+	if r.Presence != nil {
+		parts = append(parts, "presence: "+yesNo(*r.Presence))
+	}
+	if r.TemperatureC != nil {
+		parts = append(parts, fmt.Sprintf("temperature: %.1f°C", *r.TemperatureC))
+	}
+	if r.Lux != nil {
+		parts = append(parts, fmt.Sprintf("light: %.1f lux", *r.Lux))
+	}
+	if r.Dark != nil {
+		parts = append(parts, "dark: "+yesNo(*r.Dark))
+	}
+	if r.Daylight != nil {
+		parts = append(parts, "daylight: "+yesNo(*r.Daylight))
+	}
+	if r.ButtonEvent != nil {
+		parts = append(parts, fmt.Sprintf("button event: %d", *r.ButtonEvent))
+	}
+	if r.Status != nil {
+		parts = append(parts, fmt.Sprintf("status: %d", *r.Status))
+	}
+	if len(parts) == 0 {
+		return "no reading"
+	}
+	return strings.Join(parts, ", ")
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
+
+func runSensors() {
+	sensorsCmd := flag.NewFlagSet("sensors", flag.ExitOnError)
+	jsonOutput := sensorsCmd.Bool("json", false, "Output sensor readings as JSON")
+	sensorsCmd.Parse(os.Args[2:])
+
+	config, err := loadConfig()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	sensors, err := getSensors(config)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	readings := make([]SensorReading, 0, len(sensors))
+	for _, id := range sortedIDs(sensors) {
+		readings = append(readings, newSensorReading(id, sensors[id]))
+	}
+
+	if *jsonOutput {
+		out, _ := json.MarshalIndent(readings, "", "  ")
+		fmt.Println(string(out))
+		return
+	}
+
+	fmt.Println("Sensors:")
+	fmt.Println("------------------------")
+	for _, r := range readings {
+		battery := "no battery"
+		if r.Battery != nil {
+			battery = fmt.Sprintf("battery %d%%", *r.Battery)
+		}
+		updated := "never updated"
+		if t, ok := sensors[r.ID].State.lastUpdated(); ok {
+			updated = "updated " + t.Local().Format("2006-01-02 15:04:05")
+		}
+		fmt.Printf("  [%s] %s (%s) - %s - %s - %s\n", r.ID, r.Name, r.Type, battery, updated, r.describe())
+	}
+}
@@ -0,0 +1,353 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// solarElevation returns the sun's elevation above the horizon in degrees at
+// time t for the given latitude and longitude (degrees, east positive).
+// It uses the NOAA solar position equations, accurate to well under a
+// degree, and needs no network access.
+func solarElevation(t time.Time, lat, lon float64) float64 {
+	rad := math.Pi / 180
+	t = t.UTC()
+
+	// Julian century since J2000.0
+	jd := float64(t.Unix())/86400 + 2440587.5
+	jc := (jd - 2451545) / 36525
+
+	meanLong := math.Mod(280.46646+jc*(36000.76983+jc*0.0003032), 360)
+	meanAnom := 357.52911 + jc*(35999.05029-0.0001537*jc)
+	eccent := 0.016708634 - jc*(0.000042037+0.0000001267*jc)
+	center := math.Sin(meanAnom*rad)*(1.914602-jc*(0.004817+0.000014*jc)) +
+		math.Sin(2*meanAnom*rad)*(0.019993-0.000101*jc) +
+		math.Sin(3*meanAnom*rad)*0.000289
+	omega := 125.04 - 1934.136*jc
+	appLong := meanLong + center - 0.00569 - 0.00478*math.Sin(omega*rad)
+
+	meanObliq := 23 + (26+(21.448-jc*(46.815+jc*(0.00059-jc*0.001813)))/60)/60
+	obliq := meanObliq + 0.00256*math.Cos(omega*rad)
+	decl := math.Asin(math.Sin(obliq*rad) * math.Sin(appLong*rad))
+
+	// Equation of time, in minutes
+	y := math.Pow(math.Tan(obliq*rad/2), 2)
+	eqTime := 4 / rad * (y*math.Sin(2*meanLong*rad) -
+		2*eccent*math.Sin(meanAnom*rad) +
+		4*eccent*y*math.Sin(meanAnom*rad)*math.Cos(2*meanLong*rad) -
+		0.5*y*y*math.Sin(4*meanLong*rad) -
+		1.25*eccent*eccent*math.Sin(2*meanAnom*rad))
+
+	minutes := float64(t.Hour()*60+t.Minute()) + float64(t.Second())/60
+	trueSolarTime := math.Mod(minutes+eqTime+4*lon+1440, 1440)
+	hourAngle := trueSolarTime/4 - 180
+
+	cosZenith := math.Sin(lat*rad)*math.Sin(decl) + math.Cos(lat*rad)*math.Cos(decl)*math.Cos(hourAngle*rad)
+	cosZenith = math.Max(-1, math.Min(1, cosZenith))
+	return 90 - math.Acos(cosZenith)/rad
+}
+
+// configLocation returns the latitude and longitude to use for solar
+// calculations: the given flag values if set, otherwise HUE_LATITUDE and
+// HUE_LONGITUDE from the configuration
+func configLocation(latFlag, lonFlag string) (float64, float64, error) {
+	if latFlag == "" {
+		latFlag = configValue("HUE_LATITUDE")
+	}
+	if lonFlag == "" {
+		lonFlag = configValue("HUE_LONGITUDE")
+	}
+	if latFlag == "" || lonFlag == "" {
+		return 0, 0, fmt.Errorf("location not set. Use --lat/--lon, or 'hue-control config set latitude <deg>' and 'config set longitude <deg>'")
+	}
+
+	lat, err := strconv.ParseFloat(latFlag, 64)
+	if err != nil || lat < -90 || lat > 90 {
+		return 0, 0, fmt.Errorf("invalid latitude '%s', expected -90 to 90", latFlag)
+	}
+	lon, err := strconv.ParseFloat(lonFlag, 64)
+	if err != nil || lon < -180 || lon > 180 {
+		return 0, 0, fmt.Errorf("invalid longitude '%s', expected -180 to 180", lonFlag)
+	}
+	return lat, lon, nil
+}
+
+// geoLocation is a position on earth in degrees (north and east positive)
+type geoLocation struct {
+	Lat, Lon float64
+}
+
+// sunEventNames lists the sun-relative times in the order they occur in a day
+var sunEventNames = []string{"civil-dawn", "sunrise", "solar-noon", "sunset", "civil-dusk"}
+
+// sunCrossings gives the elevation the sun crosses at each event, and in
+// which direction. Sunrise and sunset allow for refraction and the sun's
+// radius; civil twilight ends with the sun 6° below the horizon.
+var sunCrossings = map[string]struct {
+	Elevation float64
+	Rising    bool
+}{
+	"civil-dawn": {-6, true},
+	"sunrise":    {-0.833, true},
+	"sunset":     {-0.833, false},
+	"civil-dusk": {-6, false},
+}
+
+// sunEventError reports that an event does not happen on a day, e.g. no
+// sunset during polar day
+type sunEventError struct {
+	Event  string
+	Date   string
+	Reason string
+}
+
+func (e *sunEventError) Error() string {
+	return fmt.Sprintf("no %s on %s at this location: %s", e.Event, e.Date, e.Reason)
+}
+
+// sunScanStep is the sampling interval used to find sun events; the exact
+// time is then refined by bisection
+const sunScanStep = 5 * time.Minute
+
+// sunTime returns when the named event happens on the calendar day
+// containing day, in day's location. The day runs from local midnight to
+// the next, so days with a DST change are 23 or 25 hours long. Dawn and
+// sunrise are the last upward crossing before solar noon, sunset and dusk
+// the first downward one after it. An event that belongs to the day but
+// falls past midnight, such as a civil dusk at 00:30 in a northern summer,
+// does not happen on this calendar day.
+func sunTime(day time.Time, event string, loc geoLocation) (time.Time, error) {
+	start := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, day.Location())
+	end := time.Date(day.Year(), day.Month(), day.Day()+1, 0, 0, 0, 0, day.Location())
+	elevation := func(t time.Time) float64 { return solarElevation(t, loc.Lat, loc.Lon) }
+
+	if event == "solar-noon" {
+		return solarNoon(start, end, elevation), nil
+	}
+
+	crossing, ok := sunCrossings[event]
+	if !ok {
+		return time.Time{}, fmt.Errorf("unknown sun event '%s', expected one of: %s", event, strings.Join(sunEventNames, ", "))
+	}
+
+	noon := solarNoon(start, end, elevation)
+	from, to := start, noon
+	if !crossing.Rising {
+		from, to = noon, end
+	}
+
+	var found time.Time
+	prev := from
+	for t := from.Add(sunScanStep); !prev.After(to); t = t.Add(sunScanStep) {
+		if t.After(to) {
+			t = to
+		}
+		a, b := elevation(prev)-crossing.Elevation, elevation(t)-crossing.Elevation
+		if (crossing.Rising && a < 0 && b >= 0) || (!crossing.Rising && a >= 0 && b < 0) {
+			lo, hi := prev, t
+			for hi.Sub(lo) > time.Second {
+				mid := lo.Add(hi.Sub(lo) / 2)
+				if (elevation(mid)-crossing.Elevation >= 0) == crossing.Rising {
+					hi = mid
+				} else {
+					lo = mid
+				}
+			}
+			found = hi.Truncate(time.Second)
+			if !crossing.Rising {
+				return found, nil
+			}
+		}
+		if t.Equal(to) {
+			break
+		}
+		prev = t
+	}
+	if !found.IsZero() {
+		return found, nil
+	}
+
+	above, below := false, false
+	for t := start; t.Before(end); t = t.Add(sunScanStep) {
+		if elevation(t) > crossing.Elevation {
+			above = true
+		} else {
+			below = true
+		}
+	}
+	var reason string
+	switch {
+	case above && below && crossing.Rising:
+		reason = "it is before midnight, on the previous day"
+	case above && below:
+		reason = "it is after midnight, on the next day"
+	case crossing.Elevation < -1 && above:
+		reason = "white night, the sun never gets 6° below the horizon"
+	case crossing.Elevation < -1:
+		reason = "the sun stays more than 6° below the horizon all day"
+	case above:
+		reason = "polar day, the sun stays above the horizon all day"
+	default:
+		reason = "polar night, the sun stays below the horizon all day"
+	}
+	return time.Time{}, &sunEventError{Event: event, Date: start.Format("2006-01-02"), Reason: reason}
+}
+
+// solarNoon returns when the sun is highest between start and end
+func solarNoon(start, end time.Time, elevation func(time.Time) float64) time.Time {
+	best := start
+	for t := start; t.Before(end); t = t.Add(sunScanStep) {
+		if elevation(t) > elevation(best) {
+			best = t
+		}
+	}
+	// Ternary search around the best sample for the highest point
+	lo, hi := best.Add(-sunScanStep), best.Add(sunScanStep)
+	for hi.Sub(lo) > time.Second {
+		m1 := lo.Add(hi.Sub(lo) / 3)
+		m2 := hi.Add(-hi.Sub(lo) / 3)
+		if elevation(m1) < elevation(m2) {
+			lo = m1
+		} else {
+			hi = m2
+		}
+	}
+	return lo.Add(hi.Sub(lo) / 2).Truncate(time.Second)
+}
+
+// timeSpec is a time of day: either a clock time, or a sun event with an
+// optional offset such as "sunset-30m"
+type timeSpec struct {
+	Hour, Minute int
+	Event        string // "" for clock times
+	Offset       time.Duration
+}
+
+// parseTimeSpec parses "HH:MM", or a sun event optionally followed by a
+// +/- duration: "sunrise+15m", "sunset-1h30m", "civil-dusk", "solar-noon"
+func parseTimeSpec(s string) (timeSpec, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if t, err := time.Parse("15:04", s); err == nil {
+		return timeSpec{Hour: t.Hour(), Minute: t.Minute()}, nil
+	}
+
+	for _, name := range sunEventNames {
+		if !strings.HasPrefix(s, name) {
+			continue
+		}
+		rest := s[len(name):]
+		spec := timeSpec{Event: name}
+		if rest == "" {
+			return spec, nil
+		}
+		if rest[0] != '+' && rest[0] != '-' {
+			break
+		}
+		offset, err := time.ParseDuration(rest[1:])
+		if err != nil {
+			return timeSpec{}, fmt.Errorf("invalid offset in '%s', expected e.g. %s-30m or %s+1h15m", s, name, name)
+		}
+		if rest[0] == '-' {
+			offset = -offset
+		}
+		spec.Offset = offset
+		return spec, nil
+	}
+	return timeSpec{}, fmt.Errorf("invalid time '%s', expected HH:MM or a sun time such as sunset-30m (%s)", s, strings.Join(sunEventNames, ", "))
+}
+
+// sunRelative reports whether the spec needs a location to resolve
+func (ts timeSpec) sunRelative() bool {
+	return ts.Event != ""
+}
+
+// on returns the spec's time on the calendar day containing day, in day's
+// location. loc may be nil for clock times.
+func (ts timeSpec) on(day time.Time, loc *geoLocation) (time.Time, error) {
+	if !ts.sunRelative() {
+		return time.Date(day.Year(), day.Month(), day.Day(), ts.Hour, ts.Minute, 0, 0, day.Location()), nil
+	}
+	if loc == nil {
+		return time.Time{}, fmt.Errorf("%s needs a location. Set HUE_LATITUDE and HUE_LONGITUDE", ts.Event)
+	}
+	t, err := sunTime(day, ts.Event, *loc)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return t.Add(ts.Offset), nil
+}
+
+func runSun() {
+	sunCmd := flag.NewFlagSet("sun", flag.ExitOnError)
+	date := sunCmd.String("date", "", "Date as YYYY-MM-DD (default: today)")
+	latFlag := sunCmd.String("lat", "", "Latitude in degrees (default: HUE_LATITUDE)")
+	lonFlag := sunCmd.String("lon", "", "Longitude in degrees (default: HUE_LONGITUDE)")
+	jsonOutput := sunCmd.Bool("json", false, "Output times as JSON")
+	sunCmd.Parse(os.Args[2:])
+
+	day := time.Now()
+	if *date != "" {
+		var err error
+		day, err = time.ParseInLocation("2006-01-02", *date, time.Local)
+		if err != nil {
+			fmt.Printf("Error: invalid date '%s', expected YYYY-MM-DD\n", *date)
+			os.Exit(1)
+		}
+	}
+
+	lat, lon, err := configLocation(*latFlag, *lonFlag)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	loc := geoLocation{Lat: lat, Lon: lon}
+
+	times := map[string]*time.Time{}
+	notes := map[string]string{}
+	for _, name := range sunEventNames {
+		t, err := sunTime(day, name, loc)
+		if err != nil {
+			var sunErr *sunEventError
+			if !errors.As(err, &sunErr) {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+			times[name] = nil
+			notes[name] = sunErr.Reason
+			continue
+		}
+		times[name] = &t
+	}
+
+	if *jsonOutput {
+		out, _ := json.MarshalIndent(map[string]interface{}{
+			"date":      day.Format("2006-01-02"),
+			"latitude":  lat,
+			"longitude": lon,
+			"times":     times,
+		}, "", "  ")
+		fmt.Println(string(out))
+		return
+	}
+
+	fmt.Printf("Sun times for %s at %.4f, %.4f\n", day.Format("Mon 2006-01-02"), lat, lon)
+	for _, name := range sunEventNames {
+		if t := times[name]; t != nil {
+			zone, _ := t.Zone()
+			fmt.Printf("  %-11s %s %s\n", name, t.Format("15:04"), zone)
+		} else {
+			fmt.Printf("  %-11s none (%s)\n", name, notes[name])
+		}
+	}
+	if rise, set := times["sunrise"], times["sunset"]; rise != nil && set != nil && set.After(*rise) {
+		length := set.Sub(*rise).Round(time.Minute)
+		fmt.Printf("  %-11s %dh%02dm\n", "day length", int(length.Hours()), int(length.Minutes())%60)
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSunTime(t *testing.T) {
+	oslo, err := time.LoadLocation("Europe/Oslo")
+	if err != nil {
+		t.Skip("no time zone data:", err)
+	}
+	midsummer := time.Date(2026, 6, 21, 12, 0, 0, 0, oslo)
+	equinox := time.Date(2026, 3, 20, 12, 0, 0, 0, oslo)
+	osloLoc := geoLocation{Lat: 60, Lon: 10}
+	tromso := geoLocation{Lat: 69.65, Lon: 18.96}
+
+	tests := []struct {
+		day   time.Time
+		event string
+		loc   geoLocation
+		want  string // HH:MM, or "" if the event doesn't happen that day
+	}{
+		{midsummer, "civil-dawn", osloLoc, "02:09"},
+		{midsummer, "sunrise", osloLoc, "03:55"},
+		{midsummer, "solar-noon", osloLoc, "13:21"},
+		{midsummer, "sunset", osloLoc, "22:47"},
+		{midsummer, "civil-dusk", osloLoc, ""}, // after midnight, not the previous night's
+		{equinox, "sunrise", osloLoc, "06:20"},
+		{equinox, "sunset", osloLoc, "18:33"},
+		{equinox, "civil-dusk", osloLoc, "19:15"},
+		{midsummer, "sunrise", tromso, ""},
+		{time.Date(2026, 12, 21, 12, 0, 0, 0, oslo), "sunset", tromso, ""},
+	}
+	for _, tt := range tests {
+		got, err := sunTime(tt.day, tt.event, tt.loc)
+		if tt.want == "" {
+			var sunErr *sunEventError
+			if !errors.As(err, &sunErr) {
+				t.Errorf("%s on %s at %v: got %v, %v, want no event", tt.event, tt.day.Format("2006-01-02"), tt.loc, got, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s on %s at %v: %v", tt.event, tt.day.Format("2006-01-02"), tt.loc, err)
+			continue
+		}
+		want, _ := time.ParseInLocation("2006-01-02 15:04", tt.day.Format("2006-01-02 ")+tt.want, oslo)
+		if d := got.Sub(want); d < -2*time.Minute || d > 2*time.Minute {
+			t.Errorf("%s on %s at %v = %s, want about %s", tt.event, tt.day.Format("2006-01-02"), tt.loc, got.Format("15:04"), tt.want)
+		}
+	}
+}
+
+func TestParseTimeSpec(t *testing.T) {
+	tests := []struct {
+		in   string
+		want timeSpec
+	}{
+		{"07:30", timeSpec{Hour: 7, Minute: 30}},
+		{"sunset", timeSpec{Event: "sunset"}},
+		{"Sunset-30m", timeSpec{Event: "sunset", Offset: -30 * time.Minute}},
+		{"sunrise+1h15m", timeSpec{Event: "sunrise", Offset: 75 * time.Minute}},
+		{"civil-dusk", timeSpec{Event: "civil-dusk"}},
+	}
+	for _, tt := range tests {
+		got, err := parseTimeSpec(tt.in)
+		if err != nil || got != tt.want {
+			t.Errorf("parseTimeSpec(%q) = %+v, %v, want %+v", tt.in, got, err, tt.want)
+		}
+	}
+
+	for _, in := range []string{"25:00", "sunset-", "sunset*2", "noon", ""} {
+		if _, err := parseTimeSpec(in); err == nil {
+			t.Errorf("parseTimeSpec(%q): no error", in)
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// LightStatus is the actual state of one light, as shown by 'status'
+type LightStatus struct {
+	ID         string `json:"id"`
+	Name       string `json:"name"`
+	On         bool   `json:"on"`
+	Brightness int    `json:"brightness"`
+	Color      string `json:"color,omitempty"`
+	Reachable  bool   `json:"reachable"`
+}
+
+// RoomStatus summarizes the member lights of a room or zone
+type RoomStatus struct {
+	ID     string        `json:"id"`
+	Name   string        `json:"name"`
+	Type   string        `json:"type"`
+	On     int           `json:"lights_on"`
+	Total  int           `json:"lights_total"`
+	Lights []LightStatus `json:"lights"`
+}
+
+func runStatus() {
+	statusCmd := flag.NewFlagSet("status", flag.ExitOnError)
+	room := statusCmd.String("room", "", "Only show this room")
+	jsonOutput := statusCmd.Bool("json", false, "Output status as JSON")
+	statusCmd.Parse(os.Args[2:])
+
+	config, err := loadConfig()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	groups, err := getGroups(config)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	lights, err := getLights(config)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	var rooms []RoomStatus
+	if *room != "" {
+		groupID, ok := findGroup(groups, *room)
+		if !ok {
+			fmt.Printf("Error: room '%s' not found. Use 'hue-control list' to see available rooms\n", *room)
+			os.Exit(1)
+		}
+		rooms = append(rooms, newRoomStatus(groupID, groups[groupID], lights))
+	} else {
+		rooms = buildRoomStatuses(groups, lights)
+	}
+
+	if *jsonOutput {
+		out, _ := json.MarshalIndent(rooms, "", "  ")
+		fmt.Println(string(out))
+		return
+	}
+
+	for i, r := range rooms {
+		if i > 0 {
+			fmt.Println()
+		}
+		fmt.Printf("%s (%s) - %d of %d on\n", r.Name, r.Type, r.On, r.Total)
+		for _, l := range r.Lights {
+			fmt.Printf("  [%s] %s - %s\n", l.ID, l.Name, l.describe())
+		}
+	}
+}
+
+// buildRoomStatuses returns every room and zone sorted by name, followed by
+// the lights that are not in any room
+func buildRoomStatuses(groups map[string]Group, lights map[string]Light) []RoomStatus {
+	var rooms []RoomStatus
+	inRoom := map[string]bool{}
+	for _, id := range sortedIDs(groups) {
+		group := groups[id]
+		if group.Type != "Room" && group.Type != "Zone" {
+			continue
+		}
+		rooms = append(rooms, newRoomStatus(id, group, lights))
+		if group.Type == "Room" {
+			for _, lightID := range group.Lights {
+				inRoom[lightID] = true
+			}
+		}
+	}
+	sort.SliceStable(rooms, func(i, j int) bool {
+		return strings.ToLower(rooms[i].Name) < strings.ToLower(rooms[j].Name)
+	})
+
+	var unassigned []string
+	for _, id := range sortedIDs(lights) {
+		if !inRoom[id] {
+			unassigned = append(unassigned, id)
+		}
+	}
+	if len(unassigned) > 0 {
+		rooms = append(rooms, newRoomStatus("", Group{Name: "Not in a room", Type: "Lights", Lights: unassigned}, lights))
+	}
+
+	return rooms
+}
+
+func newRoomStatus(id string, group Group, lights map[string]Light) RoomStatus {
+	status := RoomStatus{ID: id, Name: group.Name, Type: group.Type, Lights: []LightStatus{}}
+	for _, lightID := range group.Lights {
+		light, ok := lights[lightID]
+		if !ok {
+			continue
+		}
+		ls := LightStatus{
+			ID:         lightID,
+			Name:       light.Name,
+			On:         light.State.On,
+			Brightness: briToPercent(light.State.Bri),
+			Color:      nearestPreset(light.State),
+			Reachable:  light.State.Reachable,
+		}
+		status.Lights = append(status.Lights, ls)
+		status.Total++
+		if ls.On && ls.Reachable {
+			status.On++
+		}
+	}
+	sort.SliceStable(status.Lights, func(i, j int) bool {
+		return strings.ToLower(status.Lights[i].Name) < strings.ToLower(status.Lights[j].Name)
+	})
+	return status
+}
+
+// describe returns e.g. "on 75% warm", "off" or "unreachable"
+func (l LightStatus) describe() string {
+	if !l.Reachable {
+		return "unreachable"
+	}
+	if !l.On {
+		return "off"
+	}
+	desc := "on"
+	// On/off plugs report no brightness
+	if l.Brightness > 0 {
+		desc += fmt.Sprintf(" %d%%", l.Brightness)
+	}
+	if l.Color != "" {
+		desc += " " + l.Color
+	}
+	return desc
+}
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Tolerances when comparing the state a light reports with the one sent
+const (
+	verifyBriTolerance = 3    // bridge brightness units (1-254)
+	verifyCTTolerance  = 5    // mireds
+	verifyXYTolerance  = 0.01 // distance in xy
+	verifyHueTolerance = 400  // of 65536
+	verifySatTolerance = 5
+)
+
+// sentState is what was sent to a light, and the error if the bridge
+// refused some or all of it
+type sentState struct {
+	State map[string]interface{}
+	Err   error
+}
+
+// sentStates holds the last state sent to each light while recording is
+// on (set --verify), so it can be compared with what the lights report
+var (
+	sentStatesMu sync.Mutex
+	sentStates   map[string]*sentState
+)
+
+// recordSentStates starts recording the states sent by setLightState
+func recordSentStates() {
+	sentStatesMu.Lock()
+	defer sentStatesMu.Unlock()
+	sentStates = map[string]*sentState{}
+}
+
+// recordSentState records a state before it is sent to a light
+func recordSentState(lightID string, state map[string]interface{}) {
+	sentStatesMu.Lock()
+	defer sentStatesMu.Unlock()
+	if sentStates == nil {
+		return
+	}
+	sent, ok := sentStates[lightID]
+	if !ok {
+		sent = &sentState{State: map[string]interface{}{}}
+		sentStates[lightID] = sent
+	}
+	for key, value := range state {
+		sent.State[key] = value
+	}
+}
+
+// recordSendResult records the outcome of sending the last recorded state.
+// A policy denial means nothing was sent, so the light isn't checked.
+func recordSendResult(lightID string, err error) {
+	sentStatesMu.Lock()
+	defer sentStatesMu.Unlock()
+	sent, ok := sentStates[lightID]
+	if !ok || err == nil {
+		return
+	}
+	var denied *PolicyError
+	if errors.As(err, &denied) {
+		delete(sentStates, lightID)
+		return
+	}
+	sent.Err = err
+}
+
+// sentStateCount returns the number of lights a state was recorded for
+func sentStateCount() int {
+	sentStatesMu.Lock()
+	defer sentStatesMu.Unlock()
+	return len(sentStates)
+}
+
+// gamuts are the color gamut triangles (red, green, blue corners) of the
+// Hue light generations; lights clamp colors outside theirs to the edge
+var gamuts = map[string][3][2]float64{
+	"A": {{0.704, 0.296}, {0.2151, 0.7106}, {0.138, 0.08}},
+	"B": {{0.675, 0.322}, {0.409, 0.518}, {0.167, 0.04}},
+	"C": {{0.6915, 0.3083}, {0.17, 0.7}, {0.1532, 0.0475}},
+}
+
+// verifySentStates waits for the transitions to finish, re-reads the
+// lights that were sent a state and compares them within tolerances. It
+// returns the number of lights checked and a description of each light
+// that doesn't match or that the bridge refused to set.
+func verifySentStates(config *Config) (int, []string, error) {
+	sentStatesMu.Lock()
+	sent := sentStates
+	sentStates = nil
+	sentStatesMu.Unlock()
+
+	// The default transition is 400ms; give the lights a moment more
+	transition := 4
+	for _, s := range sent {
+		if tt, ok := s.State["transitiontime"]; ok && intValue(tt) > transition {
+			transition = intValue(tt)
+		}
+	}
+	time.Sleep(time.Duration(transition)*100*time.Millisecond + 600*time.Millisecond)
+
+	lights, err := getLights(config)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	var mismatches []string
+	for _, id := range sortedIDs(sent) {
+		light, ok := lights[id]
+		if !ok {
+			mismatches = append(mismatches, fmt.Sprintf("light %s: no longer exists", id))
+			continue
+		}
+		diffs := compareLightState(light, sent[id].State)
+		if sent[id].Err != nil {
+			diffs = append([]string{fmt.Sprintf("not set (%v)", sent[id].Err)}, diffs...)
+		}
+		if len(diffs) > 0 {
+			mismatches = append(mismatches, fmt.Sprintf("%s: %s", light.Name, strings.Join(diffs, "; ")))
+		}
+	}
+	return len(sent), mismatches, nil
+}
+
+// compareLightState lists how a light's reported state differs from the
+// state sent to it; fields that weren't sent are not compared
+func compareLightState(light Light, state map[string]interface{}) []string {
+	if !light.State.Reachable {
+		return []string{"unreachable"}
+	}
+	var diffs []string
+	if on, ok := state["on"].(bool); ok && on != light.State.On {
+		if on {
+			return []string{"off, expected on"}
+		}
+		return []string{"on, expected off"}
+	}
+	if !light.State.On {
+		// Lights that are off report their last values; nothing else to compare
+		return nil
+	}
+
+	if v, ok := state["bri"]; ok && abs(light.State.Bri-intValue(v)) > verifyBriTolerance {
+		diffs = append(diffs, fmt.Sprintf("brightness %d%%, expected %d%%", briToPercent(light.State.Bri), briToPercent(intValue(v))))
+	}
+	if v, ok := state["ct"]; ok {
+		if light.State.ColorMode != "ct" || abs(light.State.CT-intValue(v)) > verifyCTTolerance {
+			diffs = append(diffs, fmt.Sprintf("%s, expected ct %d", describeColorMode(light.State), intValue(v)))
+		}
+	}
+	if xy, ok := xyValue(state["xy"]); ok {
+		expected := clampToGamut(xy, light.Capabilities.Control.ColorGamutType)
+		if light.State.ColorMode != "xy" || len(light.State.XY) != 2 ||
+			math.Hypot(light.State.XY[0]-expected[0], light.State.XY[1]-expected[1]) > verifyXYTolerance {
+			diffs = append(diffs, fmt.Sprintf("%s, expected xy [%.4f, %.4f]", describeColorMode(light.State), expected[0], expected[1]))
+		}
+	}
+	_, hasHue := state["hue"]
+	_, hasSat := state["sat"]
+	if hasHue || hasSat {
+		wrong := light.State.ColorMode != "hs"
+		if hasHue {
+			d := abs(light.State.Hue - intValue(state["hue"]))
+			wrong = wrong || min(d, 65536-d) > verifyHueTolerance
+		}
+		if hasSat {
+			wrong = wrong || abs(light.State.Sat-intValue(state["sat"])) > verifySatTolerance
+		}
+		if wrong {
+			diffs = append(diffs, fmt.Sprintf("%s, expected hue %v sat %v", describeColorMode(light.State), state["hue"], state["sat"]))
+		}
+	}
+	return diffs
+}
+
+// describeColorMode returns a light's current color, e.g. "ct 366"
+func describeColorMode(state LightState) string {
+	switch state.ColorMode {
+	case "ct":
+		return fmt.Sprintf("ct %d", state.CT)
+	case "xy":
+		if len(state.XY) == 2 {
+			return fmt.Sprintf("xy [%.4f, %.4f]", state.XY[0], state.XY[1])
+		}
+	case "hs":
+		return fmt.Sprintf("hue %d sat %d", state.Hue, state.Sat)
+	}
+	return "no color"
+}
+
+// clampToGamut returns the color a light with the gamut will show for xy:
+// xy itself if inside, otherwise the closest point on the triangle's edge
+func clampToGamut(xy [2]float64, gamutType string) [2]float64 {
+	g, ok := gamuts[gamutType]
+	if !ok {
+		return xy
+	}
+	side := func(a, b [2]float64) float64 {
+		return (b[0]-a[0])*(xy[1]-a[1]) - (b[1]-a[1])*(xy[0]-a[0])
+	}
+	s1, s2, s3 := side(g[0], g[1]), side(g[1], g[2]), side(g[2], g[0])
+	if (s1 >= 0 && s2 >= 0 && s3 >= 0) || (s1 <= 0 && s2 <= 0 && s3 <= 0) {
+		return xy
+	}
+
+	best, bestDistance := xy, math.MaxFloat64
+	for i := 0; i < 3; i++ {
+		a, b := g[i], g[(i+1)%3]
+		dx, dy := b[0]-a[0], b[1]-a[1]
+		t := ((xy[0]-a[0])*dx + (xy[1]-a[1])*dy) / (dx*dx + dy*dy)
+		t = math.Max(0, math.Min(1, t))
+		p := [2]float64{a[0] + t*dx, a[1] + t*dy}
+		if d := math.Hypot(xy[0]-p[0], xy[1]-p[1]); d < bestDistance {
+			best, bestDistance = p, d
+		}
+	}
+	return best
+}
+
+// finishVerify reports the result of set --verify and exits with status 2
+// if any light didn't reach the requested state. partial means some lights
+// could not be set at all, which is a partial failure as well.
+func finishVerify(config *Config, partial bool) {
+	checked, mismatches, err := verifySentStates(config)
+	if err != nil {
+		fmt.Printf("Error: failed to verify: %v\n", err)
+		os.Exit(1)
+	}
+	if len(mismatches) == 0 {
+		fmt.Printf("Verified %d lights\n", checked)
+	} else {
+		fmt.Printf("%d of %d lights didn't reach the requested state:\n", len(mismatches), checked)
+		for _, m := range mismatches {
+			fmt.Printf("  %s\n", m)
+		}
+	}
+	if partial || len(mismatches) > 0 {
+		os.Exit(2)
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestClampToGamut(t *testing.T) {
+	tests := []struct {
+		name  string
+		xy    [2]float64
+		gamut string
+		want  [2]float64
+	}{
+		{"inside", [2]float64{0.4, 0.4}, "C", [2]float64{0.4, 0.4}},
+		{"unknown gamut", [2]float64{0.8, 0.3}, "", [2]float64{0.8, 0.3}},
+		{"beyond the red corner", [2]float64{0.8, 0.3}, "B", [2]float64{0.675, 0.322}},
+		{"outside the green-blue edge", [2]float64{0.156698, 0.397727}, "A", [2]float64{0.17655, 0.3953}},
+		{"gamut C red outside gamut B", [2]float64{0.6915, 0.3083}, "B", [2]float64{0.675, 0.322}},
+	}
+	for _, tt := range tests {
+		got := clampToGamut(tt.xy, tt.gamut)
+		if math.Hypot(got[0]-tt.want[0], got[1]-tt.want[1]) > 1e-4 {
+			t.Errorf("%s: clampToGamut(%v, %q) = %v, want %v", tt.name, tt.xy, tt.gamut, got, tt.want)
+		}
+	}
+}
+
+func TestCompareLightState(t *testing.T) {
+	state := func(on bool, mode string) LightState {
+		return LightState{On: on, Bri: 200, Hue: 8000, Sat: 200, XY: []float64{0.5, 0.4}, CT: 366, ColorMode: mode, Reachable: true}
+	}
+	light := func(s LightState) Light {
+		l := Light{Name: "Lamp", Type: "Extended color light", State: s}
+		l.Capabilities.Control.ColorGamutType = "B"
+		return l
+	}
+	unreachable := state(true, "ct")
+	unreachable.Reachable = false
+
+	tests := []struct {
+		name  string
+		light Light
+		sent  map[string]interface{}
+		want  []string // prefixes of the differences
+	}{
+		{"matches", light(state(true, "ct")), map[string]interface{}{"on": true, "bri": 202, "ct": 370}, nil},
+		{"unreachable", light(unreachable), map[string]interface{}{"on": true}, []string{"unreachable"}},
+		{"still off", light(state(false, "ct")), map[string]interface{}{"on": true, "bri": 100}, []string{"off, expected on"}},
+		{"still on", light(state(true, "ct")), map[string]interface{}{"on": false}, []string{"on, expected off"}},
+		{"off lights keep their values", light(state(false, "ct")), map[string]interface{}{"bri": 100, "ct": 153}, nil},
+		{"brightness", light(state(true, "ct")), map[string]interface{}{"bri": 100}, []string{"brightness "}},
+		{"ct", light(state(true, "ct")), map[string]interface{}{"ct": 153}, []string{"ct 366, expected ct 153"}},
+		{"ct while showing a color", light(state(true, "xy")), map[string]interface{}{"ct": 366}, []string{"xy [0.5000, 0.4000], expected ct 366"}},
+		{"xy", light(state(true, "xy")), map[string]interface{}{"xy": [2]float64{0.5, 0.405}}, nil},
+		{"xy clamped to the gamut", light(LightState{On: true, XY: []float64{0.675, 0.322}, ColorMode: "xy", Reachable: true}),
+			map[string]interface{}{"xy": []interface{}{0.8, 0.3}}, nil},
+		{"xy differs", light(state(true, "xy")), map[string]interface{}{"xy": [2]float64{0.3, 0.3}}, []string{"xy [0.5000, 0.4000], expected xy [0.3000, 0.3000]"}},
+		{"hue wraps around", light(LightState{On: true, Hue: 65500, Sat: 254, ColorMode: "hs", Reachable: true}),
+			map[string]interface{}{"hue": 100, "sat": 254}, nil},
+		{"hue/sat differs", light(state(true, "hs")), map[string]interface{}{"hue": 46920, "sat": 254}, []string{"hue 8000 sat 200, expected hue 46920 sat 254"}},
+		{"several", light(state(true, "ct")), map[string]interface{}{"bri": 50, "ct": 153}, []string{"brightness ", "ct 366, expected ct 153"}},
+	}
+	for _, tt := range tests {
+		got := compareLightState(tt.light, tt.sent)
+		ok := len(got) == len(tt.want)
+		for i := 0; ok && i < len(got); i++ {
+			ok = strings.HasPrefix(got[i], tt.want[i])
+		}
+		if !ok {
+			t.Errorf("%s: compareLightState = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRecordSentStates(t *testing.T) {
+	defer func() { sentStates = nil }()
+
+	recordSentState("1", map[string]interface{}{"on": true})
+	if sentStateCount() != 0 {
+		t.Fatal("recorded a state while recording is off")
+	}
+
+	recordSentStates()
+	recordSentState("1", map[string]interface{}{"on": true, "bri": 100})
+	recordSentState("1", map[string]interface{}{"bri": 200, "ct": 366})
+	recordSendResult("1", nil)
+	recordSentState("2", map[string]interface{}{"on": true})
+	recordSendResult("2", errors.New("device (2) is not reachable"))
+	recordSentState("3", map[string]interface{}{"on": true})
+	recordSendResult("3", &PolicyError{Rule: policyProtectedRoom})
+
+	if n := sentStateCount(); n != 2 {
+		t.Fatalf("recorded %d lights, want 2 (the denied one isn't checked)", n)
+	}
+	if want := map[string]interface{}{"on": true, "bri": 200, "ct": 366}; !reflect.DeepEqual(sentStates["1"].State, want) || sentStates["1"].Err != nil {
+		t.Errorf("light 1: %+v, want the merged state %v", *sentStates["1"], want)
+	}
+	if sentStates["2"].Err == nil {
+		t.Error("light 2: the bridge error wasn't recorded")
+	}
+}
@@ -0,0 +1,390 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// webhookConfig is the YAML file read by 'webhook --config'
+type webhookConfig struct {
+	Listen string         `yaml:"listen"`
+	Secret string         `yaml:"secret"` // default HMAC secret for all routes
+	Routes []webhookRoute `yaml:"routes"`
+}
+
+// webhookRoute maps a URL path to the actions it triggers
+type webhookRoute struct {
+	Path            string          `yaml:"path"`
+	Secret          string          `yaml:"secret"`
+	SignatureHeader string          `yaml:"signature_header"`
+	Actions         []webhookAction `yaml:"actions"`
+}
+
+// webhookAction is one light action. Every field is a Go template rendered
+// against the request's JSON body, e.g. color: '{{ if eq .status "success" }}green{{ else }}red{{ end }}'.
+type webhookAction struct {
+	Type       string `yaml:"type"` // set, scene, notify, on or off
+	When       string `yaml:"when"` // run only if this renders to "true"
+	Room       string `yaml:"room"`
+	Light      string `yaml:"light"`
+	Color      string `yaml:"color"`
+	Brightness string `yaml:"brightness"`
+	Scene      string `yaml:"scene"`
+	Count      string `yaml:"count"`
+
+	templates map[string]*template.Template
+}
+
+// defaultSignatureHeader carries "sha256=<hex HMAC of the body>", as sent by
+// GitHub, Gitea and many other systems
+const defaultSignatureHeader = "X-Hub-Signature-256"
+
+// maxWebhookBody limits the size of accepted request bodies
+const maxWebhookBody = 1 << 20
+
+var webhookActionTypes = []string{"set", "scene", "notify", "on", "off"}
+
+// loadWebhookConfig reads and checks the YAML config. Secrets may refer to
+// environment variables as ${NAME}.
+func loadWebhookConfig(path string) (*webhookConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var cfg webhookConfig
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("invalid webhook config %s: %v", path, err)
+	}
+	if len(cfg.Routes) == 0 {
+		return nil, fmt.Errorf("webhook config %s has no routes", path)
+	}
+
+	seen := map[string]bool{}
+	for i := range cfg.Routes {
+		route := &cfg.Routes[i]
+		if !strings.HasPrefix(route.Path, "/") {
+			return nil, fmt.Errorf("route %d: path must start with /", i+1)
+		}
+		if seen[route.Path] {
+			return nil, fmt.Errorf("route %s is defined twice", route.Path)
+		}
+		seen[route.Path] = true
+
+		if route.Secret == "" {
+			route.Secret = cfg.Secret
+		}
+		route.Secret = os.ExpandEnv(route.Secret)
+		if route.Secret == "" {
+			return nil, fmt.Errorf("route %s has no secret; every route must be signed", route.Path)
+		}
+		if route.SignatureHeader == "" {
+			route.SignatureHeader = defaultSignatureHeader
+		}
+		if len(route.Actions) == 0 {
+			return nil, fmt.Errorf("route %s has no actions", route.Path)
+		}
+		for j := range route.Actions {
+			if err := route.Actions[j].compile(); err != nil {
+				return nil, fmt.Errorf("route %s, action %d: %v", route.Path, j+1, err)
+			}
+		}
+	}
+	return &cfg, nil
+}
+
+// compile checks the action and parses its templates
+func (a *webhookAction) compile() error {
+	known := false
+	for _, t := range webhookActionTypes {
+		known = known || a.Type == t
+	}
+	if !known {
+		return fmt.Errorf("unknown type '%s', expected one of: %s", a.Type, strings.Join(webhookActionTypes, ", "))
+	}
+	if a.Room == "" && (a.Light == "" || a.Type == "scene" || a.Type == "on" || a.Type == "off") {
+		return fmt.Errorf("%s needs a room", a.Type)
+	}
+	if a.Type == "scene" && a.Scene == "" {
+		return fmt.Errorf("scene needs a scene name")
+	}
+
+	fields := map[string]string{
+		"when": a.When, "room": a.Room, "light": a.Light, "color": a.Color,
+		"brightness": a.Brightness, "scene": a.Scene, "count": a.Count,
+	}
+	a.templates = map[string]*template.Template{}
+	for name, text := range fields {
+		if text == "" {
+			continue
+		}
+		t, err := template.New(name).Funcs(webhookTemplateFuncs).Option("missingkey=error").Parse(text)
+		if err != nil {
+			return fmt.Errorf("invalid %s template: %v", name, err)
+		}
+		a.templates[name] = t
+	}
+	return nil
+}
+
+// webhookTemplateFuncs are available in action templates. field looks up a
+// dotted path such as "build.status" and returns "" if it is missing.
+var webhookTemplateFuncs = template.FuncMap{
+	"field": func(string) interface{} { return "" }, // replaced per request in render
+	"lower": strings.ToLower,
+	"upper": strings.ToUpper,
+	"default": func(fallback string, value interface{}) string {
+		if s := fmt.Sprint(value); value != nil && s != "" {
+			return s
+		}
+		return fallback
+	},
+}
+
+func lookupField(body map[string]interface{}, path string) interface{} {
+	var current interface{} = body
+	for _, key := range strings.Split(path, ".") {
+		m, ok := current.(map[string]interface{})
+		if !ok {
+			return ""
+		}
+		if current, ok = m[key]; !ok {
+			return ""
+		}
+	}
+	return current
+}
+
+// render returns the action's fields filled in from the request body
+func (a webhookAction) render(body map[string]interface{}) (map[string]string, error) {
+	values := map[string]string{}
+	for name, t := range a.templates {
+		var buf bytes.Buffer
+		err := template.Must(t.Clone()).Funcs(template.FuncMap{
+			"field": func(path string) interface{} { return lookupField(body, path) },
+		}).Execute(&buf, body)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", name, err)
+		}
+		values[name] = strings.TrimSpace(buf.String())
+	}
+	return values, nil
+}
+
+// verifySignature checks an HMAC-SHA256 signature given as "sha256=<hex>" or bare hex
+func verifySignature(secret string, body []byte, signature string) bool {
+	signature = strings.TrimPrefix(strings.TrimSpace(signature), "sha256=")
+	got, err := hex.DecodeString(signature)
+	if err != nil || len(got) == 0 {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hmac.Equal(got, mac.Sum(nil))
+}
+
+func runWebhook() {
+	webhookCmd := flag.NewFlagSet("webhook", flag.ExitOnError)
+	configPath := webhookCmd.String("config", "", "YAML file with routes and actions")
+	listen := webhookCmd.String("listen", "", "Address to listen on (default: from the config, or :9743)")
+	dryRun := webhookCmd.Bool("dry-run", false, "Log the actions requests would trigger without changing lights")
+	webhookCmd.Parse(os.Args[2:])
+
+	if *configPath == "" {
+		fmt.Println("Error: --config is required, e.g. --config hooks.yaml")
+		os.Exit(1)
+	}
+	hooks, err := loadWebhookConfig(*configPath)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	config, err := loadConfig()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	addr := *listen
+	if addr == "" {
+		addr = hooks.Listen
+	}
+	if addr == "" {
+		addr = ":9743"
+	}
+
+	logf := func(format string, args ...interface{}) {
+		fmt.Printf("%s  %s\n", time.Now().Format("2006-01-02 15:04:05"), fmt.Sprintf(format, args...))
+	}
+
+	mux := http.NewServeMux()
+	for _, route := range hooks.Routes {
+		route := route
+		mux.HandleFunc(route.Path, func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodPost {
+				http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+				return
+			}
+			payload, err := io.ReadAll(io.LimitReader(r.Body, maxWebhookBody+1))
+			if err != nil || len(payload) > maxWebhookBody {
+				http.Error(w, "body too large or unreadable", http.StatusBadRequest)
+				return
+			}
+			if !verifySignature(route.Secret, payload, r.Header.Get(route.SignatureHeader)) {
+				logf("%s: rejected request from %s with a missing or wrong signature", route.Path, r.RemoteAddr)
+				http.Error(w, "invalid signature", http.StatusUnauthorized)
+				return
+			}
+
+			body := map[string]interface{}{}
+			if len(bytes.TrimSpace(payload)) > 0 {
+				if err := json.Unmarshal(payload, &body); err != nil {
+					http.Error(w, "body must be a JSON object", http.StatusBadRequest)
+					return
+				}
+			}
+
+			// Render everything first so a bad payload changes no lights
+			var actions []map[string]string
+			for i, action := range route.Actions {
+				values, err := action.render(body)
+				if err != nil {
+					logf("%s: action %d: %v", route.Path, i+1, err)
+					http.Error(w, fmt.Sprintf("action %d: %v", i+1, err), http.StatusBadRequest)
+					return
+				}
+				if when, ok := values["when"]; ok && when != "true" {
+					continue
+				}
+				values["type"] = action.Type
+				actions = append(actions, values)
+			}
+
+			// Notifications can take a while, so the caller doesn't wait
+			w.WriteHeader(http.StatusAccepted)
+			fmt.Fprintf(w, "accepted %d actions\n", len(actions))
+
+			go func() {
+				for _, values := range actions {
+					desc := describeWebhookAction(values)
+					if *dryRun {
+						logf("%s: would %s", route.Path, desc)
+						continue
+					}
+					if err := runWebhookAction(config, values); err != nil {
+						logf("%s: %s failed: %v", route.Path, desc, err)
+					} else {
+						logf("%s: %s", route.Path, desc)
+					}
+				}
+			}()
+		})
+	}
+
+	for _, route := range hooks.Routes {
+		logf("Route %s: %d actions, signature in %s", route.Path, len(route.Actions), route.SignatureHeader)
+	}
+	logf("Listening for webhooks on %s", addr)
+	if err := http.ListenAndServe(addr, mux); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// describeWebhookAction returns e.g. "set Office to red at 80%"
+func describeWebhookAction(values map[string]string) string {
+	target := values["room"]
+	if values["light"] != "" {
+		target = values["light"]
+	}
+	desc := values["type"] + " " + target
+	switch values["type"] {
+	case "scene":
+		desc = fmt.Sprintf("recall scene '%s' in %s", values["scene"], target)
+	case "on", "off":
+		desc = fmt.Sprintf("turn %s %s", target, values["type"])
+	}
+	if values["color"] != "" {
+		desc += " " + values["color"]
+	}
+	if values["brightness"] != "" {
+		desc += " at " + values["brightness"] + "%"
+	}
+	return desc
+}
+
+// runWebhookAction performs one rendered action
+func runWebhookAction(config *Config, values map[string]string) error {
+	config = beginPolicyCommand(config)
+	brightness := 100
+	if values["brightness"] != "" {
+		b, err := strconv.Atoi(values["brightness"])
+		if err != nil || b < 0 || b > 100 {
+			return fmt.Errorf("invalid brightness '%s'", values["brightness"])
+		}
+		brightness = b
+	}
+	var color map[string]interface{}
+	if values["color"] != "" {
+		var err error
+		if color, err = colorState(values["color"]); err != nil {
+			return err
+		}
+		if preset, ok := colorPresets()[strings.ToLower(values["color"])]; ok && preset.Brightness != nil && values["brightness"] == "" {
+			brightness = *preset.Brightness
+		}
+	}
+
+	switch values["type"] {
+	case "set":
+		var err error
+		if values["light"] != "" {
+			_, err = setSingleLight(config, values["light"], percentToBri(brightness), color)
+		} else {
+			_, err = setRoomState(config, values["room"], percentToBri(brightness), color)
+		}
+		return err
+	case "scene":
+		return recallScene(config, values["room"], values["scene"])
+	case "on", "off":
+		return setRoomPower(config, values["room"], values["type"] == "on")
+	case "notify":
+		count := 3
+		if values["count"] != "" {
+			c, err := strconv.Atoi(values["count"])
+			if err != nil || c < 1 || c > 20 {
+				return fmt.Errorf("invalid count '%s'", values["count"])
+			}
+			count = c
+		}
+		n := notification{
+			Room:       values["room"],
+			Light:      values["light"],
+			Color:      color,
+			Brightness: brightness,
+			Count:      count,
+			OnTime:     500 * time.Millisecond,
+			OffTime:    500 * time.Millisecond,
+		}
+		if n.Light != "" {
+			n.Room = ""
+		}
+		_, err := runNotification(config, n, time.Minute, nil)
+		return err
+	}
+	return fmt.Errorf("unknown action type '%s'", values["type"])
+}
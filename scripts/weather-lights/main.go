@@ -33,13 +33,38 @@ type WttrResponse struct {
 	} `json:"nearest_area"`
 }
 
+// Result describes what was (or would be) set, printed by --json
+type Result struct {
+	Location     string `json:"location"`
+	TemperatureC int    `json:"temperature_c"`
+	Condition    string `json:"condition"`
+	WeatherCode  string `json:"weather_code"`
+	Color        string `json:"color"`
+	BaseColor    string `json:"base_color"`
+	Brightness   int    `json:"brightness"`
+	Room         string `json:"room"`
+	DryRun       bool   `json:"dry_run"`
+}
+
 func main() {
 	location := flag.String("location", "", "Location for weather (default: auto-detect)")
 	room := flag.String("room", "all", "Room to control")
 	brightness := flag.Int("brightness", 80, "Brightness percentage (0-100)")
 	dryRun := flag.Bool("dry-run", false, "Show what would be done without executing")
+	jsonOutput := flag.Bool("json", false, "Print the result as JSON; hue-control's output goes to stderr")
+	mappingsPath := flag.String("mappings", "", "JSON file mapping weather codes to hue-control presets (default: ~/.config/hue-control/weather-mappings.json if present)")
 	flag.Parse()
 
+	path, explicit := *mappingsPath, true
+	if path == "" {
+		path, explicit = defaultMappingsPath(), false
+	}
+	conditions, defaultColor, err := loadMappings(path, explicit)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
 	// Fetch weather
 	weather, err := getWeather(*location)
 	if err != nil {
@@ -65,27 +90,45 @@ func main() {
 	}
 
 	// Determine base color from weather code
-	baseColor, ok := WeatherConditions[weatherCode]
+	baseColor, ok := conditions[weatherCode]
 	if !ok {
-		baseColor = "warm" // Default to warm if unknown
+		baseColor = defaultColor
 	}
 
 	// Adjust color based on temperature
 	temp, _ := strconv.Atoi(current.TempC)
-	color := adjustColorByTemperature(baseColor, temp)
-
-	fmt.Printf("📍 Location: %s\n", locationName)
-	fmt.Printf("🌡️  Temperature: %s°C (feels like %s°C)\n", current.TempC, current.FeelsLikeC)
-	fmt.Printf("☁️  Condition: %s (code: %s)\n", weatherDesc, weatherCode)
-	fmt.Printf("💡 Setting lights to: %s at %d%% brightness", color, *brightness)
-	if color != baseColor {
-		fmt.Printf(" (adjusted from %s due to temperature)\n", baseColor)
-	} else {
-		fmt.Println()
+	color := adjustColorByTemperature(baseColor, temp, userPresetNames())
+
+	result := Result{
+		Location:     locationName,
+		TemperatureC: temp,
+		Condition:    weatherDesc,
+		WeatherCode:  weatherCode,
+		Color:        color,
+		BaseColor:    baseColor,
+		Brightness:   *brightness,
+		Room:         *room,
+		DryRun:       *dryRun,
+	}
+
+	if !*jsonOutput {
+		fmt.Printf("📍 Location: %s\n", locationName)
+		fmt.Printf("🌡️  Temperature: %s°C (feels like %s°C)\n", current.TempC, current.FeelsLikeC)
+		fmt.Printf("☁️  Condition: %s (code: %s)\n", weatherDesc, weatherCode)
+		fmt.Printf("💡 Setting lights to: %s at %d%% brightness", color, *brightness)
+		if color != baseColor {
+			fmt.Printf(" (adjusted from %s due to temperature)\n", baseColor)
+		} else {
+			fmt.Println()
+		}
 	}
 
 	if *dryRun {
-		fmt.Println("\n[Dry run - no changes made]")
+		if *jsonOutput {
+			printJSON(result)
+		} else {
+			fmt.Println("\n[Dry run - no changes made]")
+		}
 		return
 	}
 
@@ -104,12 +147,24 @@ func main() {
 
 	cmd := exec.Command(hueControlPath, args...)
 	cmd.Stdout = os.Stdout
+	if *jsonOutput {
+		// Keep stdout for the JSON result
+		cmd.Stdout = os.Stderr
+	}
 	cmd.Stderr = os.Stderr
 
 	if err := cmd.Run(); err != nil {
 		fmt.Printf("Error setting lights: %v\n", err)
 		os.Exit(1)
 	}
+	if *jsonOutput {
+		printJSON(result)
+	}
+}
+
+func printJSON(v interface{}) {
+	out, _ := json.MarshalIndent(v, "", "  ")
+	fmt.Println(string(out))
 }
 
 func getWeather(location string) (*WttrResponse, error) {
@@ -1,5 +1,13 @@
 package main
 
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
 // WeatherCondition maps weather codes to color names
 var WeatherConditions = map[string]string{
 	// wttr.in WWO codes -> color names (matching hue-control presets)
@@ -60,8 +68,96 @@ var WeatherConditions = map[string]string{
 	"395": "purple", // Moderate or heavy snow with thunder
 }
 
-// adjustColorByTemperature modifies the base color based on temperature
-func adjustColorByTemperature(baseColor string, tempC int) string {
+// MappingsFile overrides WeatherConditions. Colors may be any hue-control
+// preset name, including custom presets added with 'hue-control preset add'.
+type MappingsFile struct {
+	Conditions map[string]string `json:"conditions"`
+	Default    string            `json:"default"`
+}
+
+// defaultMappingsPath is the mappings file used when --mappings is not given
+func defaultMappingsPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "hue-control", "weather-mappings.json")
+}
+
+// loadMappings returns the weather code to color mappings and the color for
+// unknown codes, with the built-ins overridden by the mappings file. A
+// missing file is only an error when it was given explicitly.
+func loadMappings(path string, explicit bool) (map[string]string, string, error) {
+	conditions := map[string]string{}
+	for code, color := range WeatherConditions {
+		conditions[code] = color
+	}
+	defaultColor := "warm"
+
+	if path == "" {
+		return conditions, defaultColor, nil
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) && !explicit {
+		return conditions, defaultColor, nil
+	}
+	if err != nil {
+		return nil, "", err
+	}
+
+	var file MappingsFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, "", fmt.Errorf("invalid mappings file %s: %v", path, err)
+	}
+	for code, color := range file.Conditions {
+		conditions[code] = color
+	}
+	if file.Default != "" {
+		defaultColor = file.Default
+	}
+	return conditions, defaultColor, nil
+}
+
+// userPresetNames returns the names of the presets in hue-control's user
+// presets file. A missing or unreadable file has no presets.
+func userPresetNames() map[string]bool {
+	names := map[string]bool{}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return names
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "hue-control", "presets.json"))
+	if err != nil {
+		return names
+	}
+	var file struct {
+		Presets map[string]json.RawMessage `json:"presets"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return names
+	}
+	for name := range file.Presets {
+		names[strings.ToLower(name)] = true
+	}
+	return names
+}
+
+// adjustColorByTemperature modifies the base color based on temperature.
+// Only built-in color names are adjusted; custom presets, including ones
+// that replace a built-in name such as "warm", are used as-is, and a color
+// is never adjusted to a name the user has replaced.
+func adjustColorByTemperature(baseColor string, tempC int, userPresets map[string]bool) string {
+	if userPresets[strings.ToLower(baseColor)] {
+		return baseColor
+	}
+	if color := temperatureColor(baseColor, tempC); !userPresets[color] {
+		return color
+	}
+	return baseColor
+}
+
+// temperatureColor shifts a built-in color towards warmer or cooler tones
+func temperatureColor(baseColor string, tempC int) string {
 	// Temperature ranges influence color choice
 	switch {
 	case tempC < 0: